@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"client-server/internal/crypto"
+	"client-server/internal/crypto/keystore"
 	"client-server/tests/metrics"
 	test "client-server/tests/utils"
 	"crypto/ecdsa"
@@ -14,9 +16,12 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -35,21 +40,100 @@ type ConnectionInfo struct {
 	Stats        *metrics.SecurityStats
 	NonceTracker map[string]bool
 	StatsTimer   *time.Timer
+	KEM          crypto.KEM
+	// AlertReceived доставляет alert-кадры, полученные от пира, для логирования/реакции.
+	AlertReceived chan crypto.Alert
+	// CipherSuite — согласованный во время exchangeKeys набор шифров.
+	CipherSuite crypto.CipherSuite
+	// Epoch — текущая эпоха ключей сессии, продвигаемая командой /rekey.
+	Epoch uint64
+	// SigAlg — согласованная во время exchangeKeys схема RSA-подписи сообщений.
+	SigAlg string
 }
 
+// cipherSuitePreference — порядок предпочтения наборов шифров, в котором
+// ChaCha20-Poly1305 стоит первым как более быстрый на платформах без AES-NI.
+var cipherSuitePreference = []crypto.CipherSuite{crypto.SuiteChaCha20Poly1305, crypto.SuiteAES256GCM}
+
+// kemAlgoPreference определяет алгоритм KEM, используемый для обмена ключами.
+// X25519 предпочтителен как более быстрая и современная кривая; при несовпадении
+// с пиром стороны падают обратно на P-256.
+var kemAlgoPreference = "X25519"
+
+// sigAlgPreference определяет схему RSA-подписи сообщений. PSS предпочтителен
+// как рандомизированная и рекомендуемая схема; PKCS1v15 остается legacy-опцией
+// для совместимости со старыми пирами.
+var sigAlgPreference = crypto.SigAlgPSS
+
 func NewConnectionInfo() *ConnectionInfo {
 	ecdsaPriv, ecdsaPub := crypto.GenerateECDHKeys()
 	rsaPriv, rsaPub := crypto.GenerateRSAKeys()
+	return newConnectionInfoFromIdentity(ecdsaPriv, ecdsaPub, rsaPriv, rsaPub)
+}
+
+// NewConnectionInfoFromIdentity строит ConnectionInfo на основе ранее
+// сохраненной идентичности вместо генерации новых ключей.
+func NewConnectionInfoFromIdentity(id *keystore.Identity) *ConnectionInfo {
+	return newConnectionInfoFromIdentity(id.ECDSAPrivate, id.ECDSAPublic, id.RSAPrivate, id.RSAPublic)
+}
+
+func newConnectionInfoFromIdentity(ecdsaPriv *ecdsa.PrivateKey, ecdsaPub []byte, rsaPriv *rsa.PrivateKey, rsaPub []byte) *ConnectionInfo {
 	globalStats.SetKeyLength(256)
 
+	kem, err := crypto.NewKEM(kemAlgoPreference)
+	if err != nil {
+		log.Fatal("Ошибка инициализации KEM:", err)
+	}
+
 	return &ConnectionInfo{
-		ECDSAPrivate: ecdsaPriv,
-		ECDSAPublic:  ecdsaPub,
-		RSAPrivate:   rsaPriv,
-		RSAPublic:    rsaPub,
-		Stats:        globalStats,
-		NonceTracker: make(map[string]bool),
+		ECDSAPrivate:  ecdsaPriv,
+		ECDSAPublic:   ecdsaPub,
+		RSAPrivate:    rsaPriv,
+		RSAPublic:     rsaPub,
+		Stats:         globalStats,
+		NonceTracker:  make(map[string]bool),
+		KEM:           kem,
+		AlertReceived: make(chan crypto.Alert, 16),
+	}
+}
+
+// loadPassphrase читает пароль из файла, указанного флагом -passphrase-file,
+// обрезая завершающий перевод строки.
+func loadPassphrase(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// loadOrCreateIdentity загружает идентичность из identityPath, если она
+// существует, иначе генерирует новую пару ключей и сохраняет ее по этому пути.
+func loadOrCreateIdentity(identityPath, passphrasePath string) (*ConnectionInfo, error) {
+	passphrase, err := loadPassphrase(passphrasePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл пароля: %w", err)
+	}
+
+	if _, err := os.Stat(identityPath); err == nil {
+		id, err := keystore.LoadIdentity(identityPath, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return NewConnectionInfoFromIdentity(id), nil
+	}
+
+	info := NewConnectionInfo()
+	id := &keystore.Identity{
+		ECDSAPrivate: info.ECDSAPrivate,
+		ECDSAPublic:  info.ECDSAPublic,
+		RSAPrivate:   info.RSAPrivate,
+		RSAPublic:    info.RSAPublic,
+	}
+	if err := keystore.SaveIdentity(identityPath, passphrase, id); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить идентичность: %w", err)
 	}
+	return info, nil
 }
 
 func printStats() {
@@ -65,23 +149,80 @@ func printStats() {
 func exchangeKeys(conn net.Conn, info *ConnectionInfo) error {
 	encoder := gob.NewEncoder(conn)
 	decoder := gob.NewDecoder(conn)
+
+	kemPub, err := info.KEM.GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+
+	cert, err := crypto.NewIdentityCertificate(info.RSAPrivate, "client-server-peer", info.ECDSAPublic, info.RSAPublic, crypto.IdentityCertDuration, info.Stats)
+	if err != nil {
+		return err
+	}
+
 	keyData := struct {
-		ECDSA []byte
-		RSA   []byte
-	}{info.ECDSAPublic, info.RSAPublic}
+		ECDSA      []byte
+		RSA        []byte
+		KEMAlgo    string
+		KEMPub     []byte
+		CipherPref []crypto.CipherSuite
+		SigAlg     string
+		Cert       *crypto.IdentityCertificate
+	}{info.ECDSAPublic, info.RSAPublic, info.KEM.Algo(), kemPub, cipherSuitePreference, sigAlgPreference, cert}
 	if err := encoder.Encode(keyData); err != nil {
 		return err
 	}
 	var peerKeyData struct {
-		ECDSA []byte
-		RSA   []byte
+		ECDSA      []byte
+		RSA        []byte
+		KEMAlgo    string
+		KEMPub     []byte
+		CipherPref []crypto.CipherSuite
+		SigAlg     string
+		Cert       *crypto.IdentityCertificate
 	}
 	if err := decoder.Decode(&peerKeyData); err != nil {
 		return err
 	}
+
+	if peerKeyData.Cert == nil {
+		return fmt.Errorf("пир не предоставил сертификат идентичности")
+	}
+	if !bytes.Equal(peerKeyData.Cert.ECDSAPubDER, peerKeyData.ECDSA) || !bytes.Equal(peerKeyData.Cert.RSAPubDER, peerKeyData.RSA) {
+		return fmt.Errorf("сертификат идентичности не соответствует присланным ключам обмена (возможна MITM-подмена)")
+	}
+	if err := crypto.VerifyIdentityCertificate(peerKeyData.Cert, info.Stats); err != nil {
+		return fmt.Errorf("сертификат идентичности пира не прошел проверку: %w", err)
+	}
+
 	info.PeerECDSA = peerKeyData.ECDSA
 	info.PeerRSA = peerKeyData.RSA
-	info.SharedSecret = crypto.ComputeSharedSecret(info.ECDSAPrivate, info.PeerECDSA)
+
+	suite, err := crypto.NegotiateCipherSuite(cipherSuitePreference, peerKeyData.CipherPref)
+	if err != nil {
+		return err
+	}
+	info.CipherSuite = suite
+
+	if peerKeyData.SigAlg == crypto.SigAlgPSS && sigAlgPreference == crypto.SigAlgPSS {
+		info.SigAlg = crypto.SigAlgPSS
+	} else {
+		// Хотя бы одна сторона не поддерживает PSS — откатываемся на legacy PKCS1v15.
+		info.SigAlg = crypto.SigAlgPKCS1v15
+	}
+
+	if peerKeyData.KEMAlgo != info.KEM.Algo() {
+		// Пир предпочитает другой алгоритм — откатываемся на проверенный P-256 ECDH,
+		// который обе стороны умеют вычислить из уже переданных ECDSA-ключей.
+		info.SharedSecret = crypto.ComputeSharedSecret(info.ECDSAPrivate, info.PeerECDSA)
+		return nil
+	}
+
+	sharedSecret, err := info.KEM.DeriveShared(peerKeyData.KEMPub)
+	if err != nil {
+		return err
+	}
+	info.SharedSecret = sharedSecret
 	return nil
 }
 
@@ -106,34 +247,100 @@ func sendMessages(conn net.Conn, info *ConnectionInfo) {
 			fmt.Println("=====================================")
 			continue
 		}
-		msg := crypto.CreateSecureMessage(
+		if text == "/rekey" {
+			if err := rekeySession(conn, info); err != nil {
+				fmt.Println("Ошибка rekey:", err)
+			} else {
+				fmt.Printf("Секрет сессии обновлен, текущая эпоха: %d\n", info.Epoch)
+			}
+			continue
+		}
+		msg, err := crypto.CreateSecureMessageWithSigAlg(
 			[]byte(text),
 			info.SharedSecret,
 			info.ECDSAPrivate,
 			info.ECDSAPublic,
 			info.RSAPrivate,
+			info.SigAlg,
 			info.Stats,
 		)
-		if err := encoder.Encode(msg); err != nil {
+		if err != nil {
+			fmt.Println("Ошибка создания сообщения:", err)
+			continue
+		}
+		msg.Epoch = info.Epoch
+		if err := encoder.Encode(crypto.NewMessageFrame(msg)); err != nil {
 			fmt.Println("Ошибка отправки:", err)
 			break
 		}
 	}
 }
 
+// sendAlert кодирует и отправляет пиру alert-кадр по тому же соединению,
+// которое используется для обычных сообщений.
+func sendAlert(conn net.Conn, level, code uint8, msg string) error {
+	encoder := gob.NewEncoder(conn)
+	return encoder.Encode(crypto.NewAlertFrame(crypto.NewAlert(level, code, msg)))
+}
+
+// rekeySession продвигает общий секрет сессии по схеме HKDF-Expand и
+// уведомляет пира кадром KeyUpdate, чтобы обе стороны перешли к новой эпохе синхронно.
+func rekeySession(conn net.Conn, info *ConnectionInfo) error {
+	nextSecret, err := crypto.AdvanceSecret(info.SharedSecret)
+	if err != nil {
+		return err
+	}
+	info.SharedSecret = nextSecret
+	info.Epoch++
+	globalStats.RecordRekey()
+
+	encoder := gob.NewEncoder(conn)
+	return encoder.Encode(crypto.NewKeyUpdateFrame(crypto.KeyUpdate{NextEpoch: info.Epoch}))
+}
+
 func receiveMessages(conn net.Conn, info *ConnectionInfo) {
 	decoder := gob.NewDecoder(conn)
 	for {
-		var msg crypto.Message
-		if err := decoder.Decode(&msg); err != nil {
+		var frame crypto.Frame
+		if err := decoder.Decode(&frame); err != nil {
 			if err != io.EOF {
 				fmt.Println("\nОшибка получения:", err)
 			}
 			break
 		}
+
+		if frame.Type == "alert" && frame.Alert != nil {
+			info.AlertReceived <- *frame.Alert
+			fmt.Printf("\nПолучен alert от пира: код=%d, сообщение=%s\n", frame.Alert.Code, frame.Alert.Msg)
+			if frame.Alert.IsFatal() {
+				break
+			}
+			continue
+		}
+
+		if frame.Type == "keyupdate" && frame.KeyUpdate != nil {
+			nextSecret, err := crypto.AdvanceSecret(info.SharedSecret)
+			if err != nil {
+				fmt.Println("\nОшибка обновления ключа сессии:", err)
+				continue
+			}
+			info.SharedSecret = nextSecret
+			info.Epoch = frame.KeyUpdate.NextEpoch
+			fmt.Printf("\nПир обновил ключ сессии, текущая эпоха: %d\n", info.Epoch)
+			continue
+		}
+
+		if frame.Message == nil {
+			continue
+		}
+		msg := *frame.Message
+
 		nonceStr := string(msg.Nonce)
 		if _, seen := info.NonceTracker[nonceStr]; seen {
 			fmt.Println("\nАтака повторного воспроизведения! Сообщение отклонено.")
+			if err := sendAlert(conn, crypto.AlertLevelWarning, crypto.AlertReplayDetected, "replay detected"); err != nil {
+				fmt.Println("\nОшибка отправки alert:", err)
+			}
 			continue
 		}
 		info.NonceTracker[nonceStr] = true
@@ -143,6 +350,9 @@ func receiveMessages(conn net.Conn, info *ConnectionInfo) {
 		plain, err := crypto.VerifyAndDecryptMessage(msg, info.SharedSecret, info.PeerRSA, info.Stats)
 		if err != nil {
 			fmt.Println("\nОшибка проверки сообщения:", err)
+			if alertErr := sendAlert(conn, crypto.AlertLevelWarning, crypto.AlertBadRecordMAC, err.Error()); alertErr != nil {
+				fmt.Println("\nОшибка отправки alert:", alertErr)
+			}
 			continue
 		}
 		fmt.Print("\r\033[2K")
@@ -151,8 +361,32 @@ func receiveMessages(conn net.Conn, info *ConnectionInfo) {
 	}
 }
 
-func runServer(address string) {
-	info := NewConnectionInfo()
+// startMetricsServer регистрирует метрики crypto.RegisterMetrics в
+// prometheus.DefaultRegisterer (иначе promhttp.Handler внутри
+// crypto.MetricsHandler гатерит пустой реестр и ничего не отдает), монтирует
+// crypto.MetricsHandler на /metrics и запускает отдельный HTTP-слушатель в
+// фоновой горутине, если metricsAddr не пуст. Ошибки слушателя только
+// логируются, а не фатальны — сбор метрик не должен мешать основному
+// TCP-обмену сообщениями.
+func startMetricsServer(metricsAddr string) {
+	if metricsAddr == "" {
+		return
+	}
+	if err := crypto.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		log.Println("Ошибка регистрации метрик:", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", crypto.MetricsHandler())
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			log.Println("Ошибка HTTP-сервера метрик:", err)
+		}
+	}()
+}
+
+func runServer(address, identityPath, passphrasePath, metricsAddr string) {
+	startMetricsServer(metricsAddr)
+	info := newConnectionInfoForRun(identityPath, passphrasePath)
 	ln, err := net.Listen("tcp", address)
 	if err != nil {
 		log.Fatal(err)
@@ -172,8 +406,8 @@ func runServer(address string) {
 	sendMessages(conn, info)
 }
 
-func runClient(address string) {
-	info := NewConnectionInfo()
+func runClient(address, identityPath, passphrasePath string) {
+	info := newConnectionInfoForRun(identityPath, passphrasePath)
 	conn, err := net.Dial("tcp", address)
 	if err != nil {
 		log.Fatal("Ошибка подключения:", err)
@@ -187,15 +421,32 @@ func runClient(address string) {
 	sendMessages(conn, info)
 }
 
+// newConnectionInfoForRun загружает или создает ConnectionInfo на основе
+// флагов -identity/-passphrase-file, либо генерирует одноразовые ключи,
+// если постоянная идентичность не запрошена.
+func newConnectionInfoForRun(identityPath, passphrasePath string) *ConnectionInfo {
+	if identityPath == "" || passphrasePath == "" {
+		return NewConnectionInfo()
+	}
+	info, err := loadOrCreateIdentity(identityPath, passphrasePath)
+	if err != nil {
+		log.Fatal("Ошибка загрузки идентичности:", err)
+	}
+	return info
+}
+
 func main() {
 	serverMode := flag.Bool("server", false, "Запустить в режиме сервера")
 	clientMode := flag.Bool("client", false, "Запустить в режиме клиента")
 	address := flag.String("addr", "localhost:8080", "Адрес для подключения/прослушивания")
+	identityPath := flag.String("identity", "", "Путь к файлу постоянной идентичности (опционально)")
+	passphraseFile := flag.String("passphrase-file", "", "Путь к файлу с паролем для идентичности")
+	metricsAddr := flag.String("metrics-addr", "", "Адрес для HTTP-эндпоинта /metrics (Prometheus), пусто = отключено")
 	flag.Parse()
 	if *serverMode {
-		runServer(*address)
+		runServer(*address, *identityPath, *passphraseFile, *metricsAddr)
 	} else if *clientMode {
-		runClient(*address)
+		runClient(*address, *identityPath, *passphraseFile)
 	} else {
 		fmt.Println("Укажите --server или --client")
 	}
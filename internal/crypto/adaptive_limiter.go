@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+// adaptiveLimiterAdjustInterval — период пересчета лимита в adjustLoop.
+const adaptiveLimiterAdjustInterval = time.Second
+
+// adaptiveLimiterAdditiveStep — на сколько увеличивается лимит за один тик
+// adjustLoop, пока задержка остается не выше targetLatency (аддитивный рост
+// AIMD). Отказ Allow, в отличие от этого, уменьшает лимit мультипликативно
+// (см. backoff) — то же сочетание "рост линейно / падение вдвое", что и у
+// TCP-congestion control, от которого AIMD и получил название.
+const adaptiveLimiterAdditiveStep = 4
+
+// adaptiveLimiterDefaultTargetThroughput — коэффициент из формулы Литтла
+// L = target_throughput * avg_latency (ops/sec). Подобран так, чтобы при
+// типичной задержке AES-операции (доли миллисекунды) оценка L была близка к
+// прежней константе maxConcurrentOps = 100, которую этот лимитер заменяет.
+const adaptiveLimiterDefaultTargetThroughput = 200000.0
+
+const (
+	adaptiveLimiterDefaultMinLimit      = 4
+	adaptiveLimiterDefaultHardCap       = 1000
+	adaptiveLimiterDefaultInitialLimit  = 100
+	adaptiveLimiterDefaultTargetLatency = time.Millisecond
+)
+
+// defaultConcurrencyLimiter — общий для всего процесса лимитер, на который
+// опирается ConcurrencyCheck/ConcurrencyRelease.
+var defaultConcurrencyLimiter = NewAdaptiveConcurrencyLimiter(
+	adaptiveLimiterDefaultInitialLimit,
+	adaptiveLimiterDefaultMinLimit,
+	adaptiveLimiterDefaultHardCap,
+	adaptiveLimiterDefaultTargetLatency,
+)
+
+// ErrConcurrencyLimitExceeded возвращается AdaptiveConcurrencyLimiter.Allow,
+// когда число операций в полете достигло текущего лимита.
+var ErrConcurrencyLimitExceeded = errors.New("превышен лимит параллельных операций - возможная параллельная атака")
+
+// AdaptiveConcurrencyLimiter — лимитер параллельности, заменяющий прежний
+// фиксированный maxConcurrentOps = 100: плохой сигнал для DoS-защиты, так как
+// медленному CPU следует разрешать меньше параллельных операций, а быстрому —
+// больше. Вместо константы лимитер держит EWMA наблюдаемой задержки операций
+// (см. Release) и раз в adaptiveLimiterAdjustInterval пересчитывает лимит по
+// формуле Литтла L = target_throughput * avg_latency, дополнительно реагируя
+// на отказы AIMD-подобным множительным снижением (см. backoff) — лимит
+// становится самонастраивающимся, а не магическим числом.
+type AdaptiveConcurrencyLimiter struct {
+	limit    int64 // текущий лимит, атомарный доступ
+	inFlight int64 // операций в полете, атомарный доступ
+
+	mu            sync.Mutex
+	targetLatency time.Duration
+	ewmaLatencyNs float64
+
+	targetThroughput float64
+	minLimit         int64
+	hardCap          int64
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAdaptiveConcurrencyLimiter создает лимитер с начальным лимитом
+// initialLimit, границами [minLimit, hardCap] и целевой задержкой
+// targetLatency, запуская фоновую горутину периодической подстройки лимита.
+// Вызывающая сторона обязана вызвать Close, когда лимитер больше не нужен.
+func NewAdaptiveConcurrencyLimiter(initialLimit, minLimit, hardCap int64, targetLatency time.Duration) *AdaptiveConcurrencyLimiter {
+	l := &AdaptiveConcurrencyLimiter{
+		limit:            initialLimit,
+		targetLatency:    targetLatency,
+		targetThroughput: adaptiveLimiterDefaultTargetThroughput,
+		minLimit:         minLimit,
+		hardCap:          hardCap,
+		stopCh:           make(chan struct{}),
+	}
+	go l.adjustLoop()
+	return l
+}
+
+// Close останавливает фоновую горутину подстройки лимита. Повторные вызовы
+// безопасны.
+func (l *AdaptiveConcurrencyLimiter) Close() {
+	l.closeOnce.Do(func() { close(l.stopCh) })
+}
+
+// SetTargetLatency задает целевую задержку операции, относительно которой
+// adjustLoop решает, растить лимит или стягивать его к оценке формулы Литтла.
+func (l *AdaptiveConcurrencyLimiter) SetTargetLatency(d time.Duration) {
+	l.mu.Lock()
+	l.targetLatency = d
+	l.mu.Unlock()
+}
+
+// CurrentLimit возвращает текущий лимит параллельных операций.
+func (l *AdaptiveConcurrencyLimiter) CurrentLimit() int64 {
+	return atomic.LoadInt64(&l.limit)
+}
+
+// InFlight возвращает текущее количество операций в полете.
+func (l *AdaptiveConcurrencyLimiter) InFlight() int64 {
+	return atomic.LoadInt64(&l.inFlight)
+}
+
+// Allow резервирует слот параллельности, если операций в полете меньше
+// текущего лимита, иначе применяет AIMD-снижение (backoff) и возвращает
+// ErrConcurrencyLimitExceeded. Если stats не nil, решение учитывается в нем
+// через RecordConcurrencyDecision.
+func (l *AdaptiveConcurrencyLimiter) Allow(stats *metrics.SecurityStats) error {
+	limit := atomic.LoadInt64(&l.limit)
+	inFlight := atomic.AddInt64(&l.inFlight, 1)
+
+	if inFlight > limit {
+		atomic.AddInt64(&l.inFlight, -1)
+		l.backoff()
+		if stats != nil {
+			stats.RecordConcurrencyDecision(false, l.CurrentLimit())
+		}
+		return ErrConcurrencyLimitExceeded
+	}
+
+	if stats != nil {
+		stats.RecordConcurrencyDecision(true, l.CurrentLimit())
+	}
+	return nil
+}
+
+// Release освобождает слот параллельности, занятый предыдущим Allow, и
+// учитывает latency (полное время операции, включая ожидание Allow) в EWMA,
+// которой питается adjustLoop.
+func (l *AdaptiveConcurrencyLimiter) Release(latency time.Duration) {
+	atomic.AddInt64(&l.inFlight, -1)
+	l.recordLatency(latency)
+}
+
+func (l *AdaptiveConcurrencyLimiter) recordLatency(latency time.Duration) {
+	const ewmaAlpha = 0.2
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ewmaLatencyNs == 0 {
+		l.ewmaLatencyNs = float64(latency)
+		return
+	}
+	l.ewmaLatencyNs = ewmaAlpha*float64(latency) + (1-ewmaAlpha)*l.ewmaLatencyNs
+}
+
+// backoff вдвое уменьшает лимит (не ниже minLimit) — множительная часть AIMD,
+// срабатывающая немедленно при отказе Allow, не дожидаясь следующего тика
+// adjustLoop.
+func (l *AdaptiveConcurrencyLimiter) backoff() {
+	for {
+		cur := atomic.LoadInt64(&l.limit)
+		next := cur / 2
+		if next < l.minLimit {
+			next = l.minLimit
+		}
+		if next == cur {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&l.limit, cur, next) {
+			return
+		}
+	}
+}
+
+func (l *AdaptiveConcurrencyLimiter) adjustLoop() {
+	ticker := time.NewTicker(adaptiveLimiterAdjustInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+			l.adjust()
+		}
+	}
+}
+
+// adjust пересчитывает лимит раз в adaptiveLimiterAdjustInterval: если
+// средняя задержка держится в пределах targetLatency, лимит растет
+// аддитивно (AIMD); иначе он стягивается к оценке формулы Литтла
+// L = target_throughput * avg_latency, не дожидаясь следующего отказа Allow.
+func (l *AdaptiveConcurrencyLimiter) adjust() {
+	l.mu.Lock()
+	avgLatencyNs := l.ewmaLatencyNs
+	targetLatency := l.targetLatency
+	l.mu.Unlock()
+
+	if avgLatencyNs <= 0 {
+		return
+	}
+	avgLatency := time.Duration(avgLatencyNs)
+
+	var next int64
+	if avgLatency <= targetLatency {
+		next = atomic.LoadInt64(&l.limit) + adaptiveLimiterAdditiveStep
+	} else {
+		next = int64(l.targetThroughput * avgLatency.Seconds())
+	}
+
+	if next < l.minLimit {
+		next = l.minLimit
+	}
+	if next > l.hardCap {
+		next = l.hardCap
+	}
+	atomic.StoreInt64(&l.limit, next)
+}
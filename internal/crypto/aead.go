@@ -0,0 +1,201 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"client-server/tests/metrics"
+)
+
+// CipherSuite перечисляет поддерживаемые алгоритмы шифрования сессии,
+// согласуемые сторонами во время exchangeKeys.
+type CipherSuite uint8
+
+const (
+	SuiteAES256GCM CipherSuite = iota
+	SuiteChaCha20Poly1305
+	// SuiteAESCBCHMAC — легаси-конструкция SecureMessage: AES-256-CBC +
+	// HMAC-SHA256 в схеме encrypt-then-MAC (см. CreateSecureMessageWithSuite).
+	SuiteAESCBCHMAC
+	// SuiteNaClSecretboxAuth — SecureMessage поверх NaCl secretbox
+	// (XSalsa20-Poly1305) для шифрования и nacl/auth (HMAC-SHA-512-256) для
+	// дополнительной keyed-аутентификации шифротекста.
+	SuiteNaClSecretboxAuth
+)
+
+// String возвращает человекочитаемое имя набора шифров.
+func (s CipherSuite) String() string {
+	switch s {
+	case SuiteAES256GCM:
+		return "AES-256-GCM"
+	case SuiteChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	case SuiteAESCBCHMAC:
+		return "AES-256-CBC+HMAC-SHA256"
+	case SuiteNaClSecretboxAuth:
+		return "XSalsa20-Poly1305+NaClAuth"
+	default:
+		return "неизвестный набор шифров"
+	}
+}
+
+// NegotiateCipherSuite выбирает первый набор из предпочтений клиента,
+// поддерживаемый сервером (сервер выбирает первый взаимно поддерживаемый).
+func NegotiateCipherSuite(clientPreferences []CipherSuite, serverSupported []CipherSuite) (CipherSuite, error) {
+	supported := make(map[CipherSuite]bool, len(serverSupported))
+	for _, s := range serverSupported {
+		supported[s] = true
+	}
+	for _, pref := range clientPreferences {
+		if supported[pref] {
+			return pref, nil
+		}
+	}
+	return 0, errors.New("не удалось согласовать общий набор шифров")
+}
+
+// DirectionalKeys хранит симметричные ключи и префиксы nonce для одного
+// направления соединения (client->server или server->client).
+type DirectionalKeys struct {
+	Key       []byte // 32 байта
+	IVPrefix  []byte // 12 байт — базовый префикс для построения nonce
+	SeqNumber uint64 // монотонно растущий счетчик записей
+}
+
+// SessionKeySchedule хранит производные ключи для обоих направлений,
+// выведенные из общего секрета через HKDF-SHA256 с раздельными info-строками.
+type SessionKeySchedule struct {
+	ClientToServer DirectionalKeys
+	ServerToClient DirectionalKeys
+}
+
+// DeriveSessionKeySchedule выводит раздельные ключи шифрования и IV-префиксы
+// для обоих направлений канала из общего секрета.
+func DeriveSessionKeySchedule(sharedSecret []byte) (*SessionKeySchedule, error) {
+	c2sKey, err := hkdfExpand(sharedSecret, "c2s-key", 32)
+	if err != nil {
+		return nil, err
+	}
+	s2cKey, err := hkdfExpand(sharedSecret, "s2c-key", 32)
+	if err != nil {
+		return nil, err
+	}
+	c2sIV, err := hkdfExpand(sharedSecret, "c2s-iv", 12)
+	if err != nil {
+		return nil, err
+	}
+	s2cIV, err := hkdfExpand(sharedSecret, "s2c-iv", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionKeySchedule{
+		ClientToServer: DirectionalKeys{Key: c2sKey, IVPrefix: c2sIV},
+		ServerToClient: DirectionalKeys{Key: s2cKey, IVPrefix: s2cIV},
+	}, nil
+}
+
+func hkdfExpand(secret []byte, info string, length int) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, secret, nil, []byte(info))
+	out := make([]byte, length)
+	if _, err := kdf.Read(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NextNonce строит 12-байтовый nonce как XOR префикса IV с номером
+// последовательности и увеличивает счетчик направления.
+func (d *DirectionalKeys) NextNonce() []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, d.IVPrefix)
+	var seqBytes [8]byte
+	binary.BigEndian.PutUint64(seqBytes[:], d.SeqNumber)
+	for i := 0; i < 8; i++ {
+		nonce[4+i] ^= seqBytes[i]
+	}
+	d.SeqNumber++
+	return nonce
+}
+
+// ChaCha20Poly1305Encrypt шифрует plaintext с аутентификацией aad,
+// записывая время выполнения в stats.
+func ChaCha20Poly1305Encrypt(key, nonce, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+	if stats != nil {
+		stats.RecordEncryptionTime(time.Since(start))
+	}
+	return ciphertext, nil
+}
+
+// ChaCha20Poly1305Decrypt расшифровывает и проверяет ciphertext, записывая
+// время выполнения в stats.
+func ChaCha20Poly1305Decrypt(key, nonce, ciphertext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if stats != nil {
+		stats.RecordDecryptionTime(time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// AESGCMEncrypt шифрует plaintext AES-256-GCM с аутентификацией aad,
+// записывая время выполнения в stats — аналог ChaCha20Poly1305Encrypt для
+// другого набора шифров (см. AEADBySuite).
+func AESGCMEncrypt(key, nonce, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+	if stats != nil {
+		stats.RecordEncryptionTime(time.Since(start))
+	}
+	return ciphertext, nil
+}
+
+// AESGCMDecrypt расшифровывает и проверяет ciphertext, созданный
+// AESGCMEncrypt, записывая время выполнения в stats.
+func AESGCMDecrypt(key, nonce, ciphertext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if stats != nil {
+		stats.RecordDecryptionTime(time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"client-server/tests/metrics"
+)
+
+// aeadEnvelopeVersion1 — единственная версия формата конверта AEADEncrypt/
+// AEADDecrypt на сегодняшний день.
+const aeadEnvelopeVersion1 = 1
+
+// aeadEnvelopeSeqSize — размер поля номера последовательности в заголовке
+// конверта (см. формат в док-комментарии AEADEncrypt). Номер
+// аутентифицируется как часть AAD (см. seqAAD), так что подмена этого поля
+// в конверте нарушает аутентификацию AEAD — ReplayGuard.Check, получающий
+// seq от AEADDecrypt, может доверять ему ровно потому, что Open уже его
+// проверил.
+const aeadEnvelopeSeqSize = 8
+
+var (
+	// ErrAEADEnvelopeTooShort возвращается, если конверт короче минимально
+	// необходимого заголовка (версия+набор шифров, затем nonce или IV).
+	ErrAEADEnvelopeTooShort = errors.New("AEAD-конверт: данные короче минимального заголовка")
+	// ErrAEADEnvelopeVersion возвращается при несовпадении версии формата.
+	ErrAEADEnvelopeVersion = errors.New("AEAD-конверт: неподдерживаемая версия формата")
+	// ErrAEADEnvelopeLegacyCBC возвращается AEADDecrypt, если конверт
+	// помечен легаси-набором SuiteAESCBCHMAC, а AllowLegacyCBCEnvelope
+	// отключен.
+	ErrAEADEnvelopeLegacyCBC = errors.New("AEAD-конверт: легаси AES-CBC отключен (см. AllowLegacyCBCEnvelope)")
+)
+
+// AllowLegacyCBCEnvelope управляет тем, примет ли AEADDecrypt конверт с
+// suite == SuiteAESCBCHMAC — путь без AEAD-аутентификации, расшифровываемый
+// AESDecrypt/PKCS7Unpad и поэтому уязвимый к padding-oracle. Значение по
+// умолчанию true сохраняет совместимость с уже развернутыми клиентами;
+// установка в false (например, на сервере, когда все клиенты обновлены)
+// полностью закрывает эту ветку декодирования.
+var AllowLegacyCBCEnvelope = true
+
+// AEADEncrypt шифрует plaintext под suite (SuiteAES256GCM или
+// SuiteChaCha20Poly1305), аутентифицируя aad вместе с номером
+// последовательности seq, и возвращает самоописывающийся конверт
+// [version:1][suite:1][seq:8][nonce][ciphertext||tag]. В отличие от
+// AESEncrypt/AESDecrypt (AES-256-CBC+PKCS#7 — без аутентификации и уязвимый
+// к padding-oracle через PKCS7Unpad) и от AESGCMEncrypt/
+// ChaCha20Poly1305Encrypt (которым nonce нужно передавать и синхронизировать
+// отдельно, см. DirectionalKeys.NextNonce), AEADEncrypt сам генерирует
+// случайный nonce через crypto/rand и упаковывает все метаданные,
+// необходимые для расшифровки и для проверки повтора (см.
+// ReplayGuard.Check), в один самодостаточный конверт. seq обычно берется из
+// того же счетчика, что и DirectionalKeys.SeqNumber — вызывающая сторона
+// отвечает за его монотонность.
+func AEADEncrypt(suite CipherSuite, key []byte, seq uint64, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return AEADEncryptWithRand(DefaultRand, suite, key, seq, plaintext, aad, stats)
+}
+
+// AEADEncryptWithRand — вариант AEADEncrypt с настраиваемым источником
+// энтропии для nonce, тот же прием, что и у NewIVWithRand/GenerateDEKWithRand
+// — позволяет внедрить детерминированный источник (см. NewDeterministicReader)
+// для воспроизводимых тестов.
+func AEADEncryptWithRand(rnd io.Reader, suite CipherSuite, key []byte, seq uint64, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	aeadImpl, err := AEADBySuite(suite)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aeadImpl.NonceSize())
+	if _, err := io.ReadFull(rnd, nonce); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := aeadImpl.Seal(key, nonce, plaintext, seqAAD(seq, aad), stats)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := make([]byte, 0, 2+aeadEnvelopeSeqSize+len(nonce)+len(ciphertext))
+	envelope = append(envelope, aeadEnvelopeVersion1, byte(suite))
+	envelope = append(envelope, seqBytes(seq)...)
+	envelope = append(envelope, nonce...)
+	envelope = append(envelope, ciphertext...)
+	return envelope, nil
+}
+
+// AEADDecrypt разбирает конверт, созданный AEADEncrypt, проверяет версию
+// формата и расшифровывает тело под набором шифров, записанным в конверте.
+// Возвращает вместе с plaintext номер последовательности seq из заголовка —
+// он аутентифицирован как часть AAD, так что после успешного Open вызывающая
+// сторона может передать его в ReplayGuard.Check, не рискуя принять
+// подделанный номер. Конверты с suite == SuiteAESCBCHMAC обрабатываются
+// отдельной легаси-веткой (decryptLegacyCBCEnvelope), гейтованной
+// AllowLegacyCBCEnvelope, — единственный путь в пакете crypto, через который
+// padding-oracle-уязвимый AESDecrypt вообще достижим из самоописывающегося
+// конверта; у легаси-формата нет поля seq, поэтому для него всегда
+// возвращается 0 и анти-replay защита ReplayGuard недоступна.
+func AEADDecrypt(key, envelope, aad []byte, stats *metrics.SecurityStats) ([]byte, uint64, error) {
+	if len(envelope) < 2 {
+		return nil, 0, ErrAEADEnvelopeTooShort
+	}
+	version := envelope[0]
+	suite := CipherSuite(envelope[1])
+	body := envelope[2:]
+
+	if version != aeadEnvelopeVersion1 {
+		return nil, 0, ErrAEADEnvelopeVersion
+	}
+
+	if suite == SuiteAESCBCHMAC {
+		if !AllowLegacyCBCEnvelope {
+			return nil, 0, ErrAEADEnvelopeLegacyCBC
+		}
+		plaintext, err := decryptLegacyCBCEnvelope(key, body, stats)
+		return plaintext, 0, err
+	}
+
+	if len(body) < aeadEnvelopeSeqSize {
+		return nil, 0, ErrAEADEnvelopeTooShort
+	}
+	seq := binary.BigEndian.Uint64(body[:aeadEnvelopeSeqSize])
+	body = body[aeadEnvelopeSeqSize:]
+
+	aeadImpl, err := AEADBySuite(suite)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(body) < aeadImpl.NonceSize() {
+		return nil, 0, ErrAEADEnvelopeTooShort
+	}
+	nonce := body[:aeadImpl.NonceSize()]
+	ciphertext := body[aeadImpl.NonceSize():]
+
+	plaintext, err := aeadImpl.Open(key, nonce, ciphertext, seqAAD(seq, aad), stats)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plaintext, seq, nil
+}
+
+// decryptLegacyCBCEnvelope расшифровывает компат-конверт
+// [iv:aes.BlockSize][ciphertext] путем AES-256-CBC+PKCS#7 (AESDecrypt) — см.
+// предупреждение AllowLegacyCBCEnvelope о padding-oracle.
+func decryptLegacyCBCEnvelope(key, body []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	if len(body) < aes.BlockSize {
+		return nil, ErrAEADEnvelopeTooShort
+	}
+	iv := body[:aes.BlockSize]
+	ciphertext := body[aes.BlockSize:]
+	return AESDecrypt(key, iv, ciphertext, stats)
+}
+
+// seqBytes сериализует seq в big-endian для заголовка конверта.
+func seqBytes(seq uint64) []byte {
+	var b [aeadEnvelopeSeqSize]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return b[:]
+}
+
+// seqAAD добавляет seq перед aad, связывая номер последовательности с
+// аутентификацией AEAD — подмена seq в конверте без знания ключа обнаружится
+// как ошибка Open, точно так же, как и подмена самого aad.
+func seqAAD(seq uint64, aad []byte) []byte {
+	out := make([]byte, 0, aeadEnvelopeSeqSize+len(aad))
+	out = append(out, seqBytes(seq)...)
+	out = append(out, aad...)
+	return out
+}
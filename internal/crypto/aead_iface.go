@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"fmt"
+
+	"client-server/tests/metrics"
+)
+
+// AEAD — интерфейс подключаемого AEAD-шифра, позволяющий вызывающей стороне
+// (например, нагрузочным тестам в tests/benchmark) выбирать реализацию по
+// CipherSuite вместо жестко закодированных AESGCMEncrypt/
+// ChaCha20Poly1305Encrypt — тот же прием, которым MAC подключает реализации
+// аутентификации сообщений по Message.MACAlgo.
+type AEAD interface {
+	// Seal шифрует и аутентифицирует plaintext вместе с aad, возвращая
+	// шифротекст с присоединенным тегом аутентификации.
+	Seal(key, nonce, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error)
+	// Open проверяет и расшифровывает ciphertext, полученный от Seal.
+	Open(key, nonce, ciphertext, aad []byte, stats *metrics.SecurityStats) ([]byte, error)
+	// NonceSize возвращает ожидаемый размер nonce в байтах.
+	NonceSize() int
+}
+
+// AESGCMAEAD — обертка AEAD над AESGCMEncrypt/AESGCMDecrypt.
+type AESGCMAEAD struct{}
+
+func (AESGCMAEAD) Seal(key, nonce, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return AESGCMEncrypt(key, nonce, plaintext, aad, stats)
+}
+
+func (AESGCMAEAD) Open(key, nonce, ciphertext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return AESGCMDecrypt(key, nonce, ciphertext, aad, stats)
+}
+
+func (AESGCMAEAD) NonceSize() int { return aeadNonceSize }
+
+// ChaCha20Poly1305AEAD — обертка AEAD над ChaCha20Poly1305Encrypt/Decrypt.
+type ChaCha20Poly1305AEAD struct{}
+
+func (ChaCha20Poly1305AEAD) Seal(key, nonce, plaintext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return ChaCha20Poly1305Encrypt(key, nonce, plaintext, aad, stats)
+}
+
+func (ChaCha20Poly1305AEAD) Open(key, nonce, ciphertext, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return ChaCha20Poly1305Decrypt(key, nonce, ciphertext, aad, stats)
+}
+
+func (ChaCha20Poly1305AEAD) NonceSize() int { return aeadNonceSize }
+
+// AEADBySuite возвращает реализацию AEAD по CipherSuite — в отличие от
+// CreateSecureMessageAEAD (которая прячет выбор реализации внутри себя),
+// нужна там, где сам интерфейс должен остаться подключаемым, например в
+// RunLoadTestWithParams (tests/benchmark) при сравнении AES-GCM и
+// ChaCha20-Poly1305 на разных профилях клиентов.
+func AEADBySuite(suite CipherSuite) (AEAD, error) {
+	switch suite {
+	case SuiteAES256GCM:
+		return AESGCMAEAD{}, nil
+	case SuiteChaCha20Poly1305:
+		return ChaCha20Poly1305AEAD{}, nil
+	default:
+		return nil, fmt.Errorf("AEADBySuite: набор шифров %s не поддерживается", suite)
+	}
+}
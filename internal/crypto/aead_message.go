@@ -0,0 +1,203 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"client-server/tests/metrics"
+)
+
+// aeadNonceSize — размер nonce, которого требуют и AES-256-GCM, и
+// ChaCha20-Poly1305 (12 байт). Message.Nonce длиннее (NonceSize=16, общий
+// для всех схем SecureMessage), поэтому CreateSecureMessageAEAD использует
+// только его первые aeadNonceSize байт.
+const aeadNonceSize = 12
+
+var errAEADUnsupportedSuite = errors.New("AEAD: набор шифров не поддерживается (ожидается SuiteAES256GCM или SuiteChaCha20Poly1305)")
+
+// deriveAEADKey выводит 32-байтовый ключ AEAD из sharedSecret через
+// HKDF-SHA256 с меткой, различной для каждого suite — в отличие от
+// CreateSecureMessageWithSuite, где sharedSecret делится пополам на ключ
+// шифрования и ключ HMAC, здесь не нужно отдельного MAC-ключа: сама
+// AEAD-конструкция аутентифицирует шифротекст и AAD.
+func deriveAEADKey(sharedSecret []byte, suite CipherSuite) ([]byte, error) {
+	switch suite {
+	case SuiteChaCha20Poly1305:
+		return hkdfExpand(sharedSecret, "aead-chacha20poly1305-key", chacha20poly1305.KeySize)
+	case SuiteAES256GCM:
+		return hkdfExpand(sharedSecret, "aead-aes256gcm-key", AESKeySize)
+	default:
+		return nil, errAEADUnsupportedSuite
+	}
+}
+
+func newAEAD(key []byte, suite CipherSuite) (cipher.AEAD, error) {
+	switch suite {
+	case SuiteChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case SuiteAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	default:
+		return nil, errAEADUnsupportedSuite
+	}
+}
+
+// buildAEADAdditionalData собирает AAD из полей сообщения, которые должны
+// быть аутентифицированы, но не зашифрованы: Timestamp, PubKey (ECDH-ключ
+// отправителя), ECDSA- и RSA-подписи (вычисленные над plaintext — см.
+// CreateSecureMessageAEAD), и произвольный aad, переданный вызывающей
+// стороной (например, идентификатор сессии или номер канала). Подмена
+// любого из этих полей меняет AAD и AEAD.Open отвергает сообщение.
+func buildAEADAdditionalData(timestamp int64, pubKey, ecdsaSig, rsaSig, callerAAD []byte) []byte {
+	var tsBytes [8]byte
+	binary.BigEndian.PutUint64(tsBytes[:], uint64(timestamp))
+
+	out := make([]byte, 0, 8+len(pubKey)+len(ecdsaSig)+len(rsaSig)+len(callerAAD))
+	out = append(out, tsBytes[:]...)
+	out = append(out, pubKey...)
+	out = append(out, ecdsaSig...)
+	out = append(out, rsaSig...)
+	out = append(out, callerAAD...)
+	return out
+}
+
+// CreateSecureMessageAEAD собирает защищенное сообщение, заменяя
+// encrypt-then-HMAC (CreateSecureMessageWithSuite) единой AEAD-операцией:
+// suite должен быть SuiteAES256GCM или SuiteChaCha20Poly1305. Ключ AEAD
+// выводится из sharedSecret через HKDF (см. deriveAEADKey) вместо деления
+// sharedSecret пополам на AES- и HMAC-ключи. ECDSA/RSA-подписи вычисляются
+// над plaintext (а не над шифротекстом, как в легаси-схеме, — иначе
+// подписи нельзя было бы включить в AAD до шифрования) и связываются с
+// шифротекстом через AAD вместе с Timestamp и PubKey, так что подмена любого
+// из этих полей обнаруживается уже на этапе AEAD-расшифровки, до проверки
+// подписей.
+func CreateSecureMessageAEAD(plaintext []byte, sharedSecret []byte, suite CipherSuite, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, aad []byte, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	key, err := deriveAEADKey(sharedSecret, suite)
+	if err != nil {
+		return Message{}, err
+	}
+	aead, err := newAEAD(key, suite)
+	if err != nil {
+		return Message{}, err
+	}
+
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(rnd, nonce); err != nil {
+		return Message{}, err
+	}
+	timestamp := time.Now().Unix()
+
+	ecdsaSig := SignECDSA(ecdsaPriv, plaintext, stats)
+
+	var rsaSig []byte
+	switch sigAlg {
+	case SigAlgPSS:
+		rsaSig, err = SignRSAPSS(rsaPriv, plaintext, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS подписи: %w", err)
+		}
+	case SigAlgPSSMaxSalt:
+		rsaSig, err = SignRSAPSSWithSaltLength(rsaPriv, plaintext, rsa.PSSSaltLengthAuto, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS (max salt) подписи: %w", err)
+		}
+	case SigAlgPKCS1v15, "":
+		rsaSig = SignRSA(rsaPriv, plaintext, stats)
+		sigAlg = SigAlgPKCS1v15
+	default:
+		return Message{}, fmt.Errorf("неизвестная схема подписи RSA: %s", sigAlg)
+	}
+
+	additionalData := buildAEADAdditionalData(timestamp, ecdhPub, ecdsaSig, rsaSig, aad)
+
+	startTime := time.Now()
+	ciphertext := aead.Seal(nil, nonce[:aeadNonceSize], plaintext, additionalData)
+	if stats != nil {
+		stats.RecordEncryptionTime(time.Since(startTime))
+		stats.RecordCipherSuiteUsage(suite.String())
+	}
+
+	return Message{
+		Timestamp:   timestamp,
+		Nonce:       nonce,
+		Cipher:      ciphertext,
+		Signature:   ecdsaSig,
+		PubKey:      ecdhPub,
+		RSASig:      rsaSig,
+		SigAlg:      sigAlg,
+		CipherSuite: suite,
+	}, nil
+}
+
+// VerifyAndDecryptMessageAEAD проверяет и расшифровывает сообщение,
+// созданное CreateSecureMessageAEAD. aad должен совпадать с тем, что было
+// передано при создании сообщения (например, идентификатор сессии). Как и
+// VerifyAndDecryptMessage, переиспользует общее окно timestamp и глобальный
+// globalNonceCache для защиты от replay.
+func VerifyAndDecryptMessageAEAD(msg Message, sharedSecret []byte, rsaPubKey []byte, aad []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	now := time.Now().Unix()
+	if now-msg.Timestamp > MaxTimeDifference || now < msg.Timestamp-MaxTimeDifference {
+		return nil, errors.New("временная метка вне допустимого диапазона")
+	}
+	if err := globalNonceCache.AddNonce(msg.Nonce); err != nil {
+		return nil, err
+	}
+	if len(msg.Nonce) < aeadNonceSize {
+		return nil, errors.New("AEAD: nonce сообщения короче требуемых 12 байт")
+	}
+
+	key, err := deriveAEADKey(sharedSecret, msg.CipherSuite)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key, msg.CipherSuite)
+	if err != nil {
+		return nil, err
+	}
+
+	additionalData := buildAEADAdditionalData(msg.Timestamp, msg.PubKey, msg.Signature, msg.RSASig, aad)
+
+	startTime := time.Now()
+	plaintext, err := aead.Open(nil, msg.Nonce[:aeadNonceSize], msg.Cipher, additionalData)
+	if stats != nil {
+		stats.RecordDecryptionTime(time.Since(startTime))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("AEAD: аутентификация не удалась: %w", err)
+	}
+
+	if !VerifyECDSA(msg.PubKey, plaintext, msg.Signature, stats) {
+		return nil, errors.New("ECDSA-подпись недействительна")
+	}
+
+	rsaValid := false
+	switch msg.SigAlg {
+	case SigAlgPSS:
+		rsaValid = VerifyRSAPSS(rsaPubKey, plaintext, msg.RSASig, stats)
+	case SigAlgPSSMaxSalt:
+		rsaValid = VerifyRSAPSSWithSaltLength(rsaPubKey, plaintext, msg.RSASig, rsa.PSSSaltLengthAuto, stats)
+	default:
+		rsaValid = VerifyRSA(rsaPubKey, plaintext, msg.RSASig, stats)
+	}
+	if !rsaValid {
+		return nil, errors.New("RSA-подпись недействительна")
+	}
+
+	if stats != nil {
+		stats.RecordCipherSuiteUsage(msg.CipherSuite.String())
+	}
+	return plaintext, nil
+}
@@ -5,66 +5,49 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"errors"
-	"sync"
-	"sync/atomic"
+	"io"
 	"time"
 
 	"client-server/tests/metrics"
 )
 
-// Защита от параллельных атак
-var (
-	// Счетчик параллельных операций шифрования
-	concurrentOps    int64
-	maxConcurrentOps int64 = 100 // Максимальное количество параллельных операций
-	rateLimitMutex   sync.RWMutex
-	rateLimitMap     = make(map[string]time.Time) // IP -> last operation time
-	minOpInterval    = 10 * time.Millisecond      // Минимальный интервал между операциями
-)
-
-// RateLimitCheck проверяет ограничения скорости для защиты от DoS атак
+// RateLimitCheck проверяет ограничения скорости для защиты от DoS атак,
+// используя общий для всего процесса defaultRateLimiter (см. RateLimiter в
+// ratelimiter.go) — сохранено как тонкая обертка ради существующих
+// вызывающих (tests/attack_tests/enhanced_attacks.go), которым не нужен
+// доступ к per-client метрикам Allow.
 func RateLimitCheck(clientID string) error {
-	rateLimitMutex.Lock()
-	defer rateLimitMutex.Unlock()
-
-	now := time.Now()
-	if lastOp, exists := rateLimitMap[clientID]; exists {
-		if now.Sub(lastOp) < minOpInterval {
-			return errors.New("слишком частые операции - возможная DoS атака")
-		}
-	}
-
-	rateLimitMap[clientID] = now
-
-	// Очистка старых записей (старше 1 минуты)
-	cutoff := now.Add(-time.Minute)
-	for id, timestamp := range rateLimitMap {
-		if timestamp.Before(cutoff) {
-			delete(rateLimitMap, id)
-		}
+	err := defaultRateLimiter.Allow(clientID, nil)
+	if err != nil {
+		rateLimitRejectsTotal.WithLabelValues(clientID).Inc()
 	}
-
-	return nil
+	return err
 }
 
-// ConcurrencyCheck проверяет ограничения параллельности
-func ConcurrencyCheck() error {
-	current := atomic.LoadInt64(&concurrentOps)
-	if current >= maxConcurrentOps {
-		return errors.New("превышен лимит параллельных операций - возможная параллельная атака")
+// ConcurrencyCheck проверяет ограничения параллельности, используя общий для
+// всего процесса defaultConcurrencyLimiter (см. AdaptiveConcurrencyLimiter в
+// adaptive_limiter.go) — лимит подстраивается под наблюдаемую задержку
+// операций вместо прежней фиксированной константы maxConcurrentOps = 100.
+func ConcurrencyCheck(stats *metrics.SecurityStats) error {
+	if err := defaultConcurrencyLimiter.Allow(stats); err != nil {
+		concurrencyRejectsTotal.Inc()
+		return err
 	}
-	atomic.AddInt64(&concurrentOps, 1)
+	concurrentOpsGauge.Set(float64(defaultConcurrencyLimiter.InFlight()))
 	return nil
 }
 
-// ConcurrencyRelease освобождает слот параллельности
-func ConcurrencyRelease() {
-	atomic.AddInt64(&concurrentOps, -1)
+// ConcurrencyRelease освобождает слот параллельности, занятый предыдущим
+// ConcurrencyCheck. latency — полное время операции (включая ожидание
+// ConcurrencyCheck), которым питается EWMA defaultConcurrencyLimiter.
+func ConcurrencyRelease(latency time.Duration) {
+	defaultConcurrencyLimiter.Release(latency)
+	concurrentOpsGauge.Set(float64(defaultConcurrencyLimiter.InFlight()))
 }
 
 // GetConcurrentOpsCount возвращает текущее количество параллельных операций
 func GetConcurrentOpsCount() int64 {
-	return atomic.LoadInt64(&concurrentOps)
+	return defaultConcurrencyLimiter.InFlight()
 }
 
 // PKCS7Pad добавляет дополнение PKCS#7 к данным
@@ -91,17 +74,38 @@ func PKCS7Unpad(data []byte) ([]byte, error) {
 	return data[:len(data)-pad], nil
 }
 
-// AESEncrypt шифрует данные с использованием AES-256-CBC с защитой от параллельных атак
+// NewIV генерирует случайный IV размера aes.BlockSize для AESEncrypt/AESDecrypt,
+// используя DefaultRand в качестве источника энтропии.
+func NewIV() ([]byte, error) {
+	return NewIVWithRand(DefaultRand)
+}
+
+// NewIVWithRand генерирует IV размера aes.BlockSize, читая байты из
+// переданного rnd вместо DefaultRand — позволяет внедрить детерминированный
+// источник (см. NewDeterministicReader) для воспроизводимых тестов.
+func NewIVWithRand(rnd io.Reader) ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rnd, iv); err != nil {
+		return nil, err
+	}
+	return iv, nil
+}
+
+// AESEncrypt шифрует данные с использованием AES-256-CBC с защитой от
+// параллельных атак. Не аутентифицирован и не защищен от padding-oracle
+// (см. PKCS7Unpad) — для новых мест используйте AEADEncrypt/AEADDecrypt;
+// AESEncrypt/AESDecrypt остаются только как легаси-примитив под
+// SuiteAESCBCHMAC и компат-веткой AEADDecrypt (см. AllowLegacyCBCEnvelope).
 func AESEncrypt(key, iv, plaintext []byte, stats *metrics.SecurityStats) []byte {
+	startTime := time.Now()
 	// Проверка ограничений параллельности
-	if err := ConcurrencyCheck(); err != nil {
+	if err := ConcurrencyCheck(stats); err != nil {
 		// В случае превышения лимита, делаем небольшую задержку
 		time.Sleep(time.Millisecond * 50)
 		return nil
 	}
-	defer ConcurrencyRelease()
+	defer func() { ConcurrencyRelease(time.Since(startTime)) }()
 
-	startTime := time.Now()
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		panic(err)
@@ -109,29 +113,40 @@ func AESEncrypt(key, iv, plaintext []byte, stats *metrics.SecurityStats) []byte
 	plaintext = PKCS7Pad(plaintext)
 	ciphertext := make([]byte, len(plaintext))
 	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
-	stats.RecordEncryptionTime(time.Since(startTime))
+	duration := time.Since(startTime)
+	if stats != nil {
+		stats.RecordEncryptionTime(duration)
+	}
+	encryptDurationSeconds.Observe(duration.Seconds())
 	return ciphertext
 }
 
 // AESDecrypt расшифровывает данные, зашифрованные с помощью AES-256-CBC с защитой от параллельных атак
 func AESDecrypt(key, iv, ciphertext []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	startTime := time.Now()
 	// Проверка ограничений параллельности
-	if err := ConcurrencyCheck(); err != nil {
+	if err := ConcurrencyCheck(stats); err != nil {
 		return nil, err
 	}
-	defer ConcurrencyRelease()
+	defer func() { ConcurrencyRelease(time.Since(startTime)) }()
 
-	startTime := time.Now()
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("шифротекст не кратен размеру блока AES")
+	}
 	plaintext := make([]byte, len(ciphertext))
 	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
 	plaintext, err = PKCS7Unpad(plaintext)
 	if err != nil {
 		return nil, err
 	}
-	stats.RecordDecryptionTime(time.Since(startTime))
+	duration := time.Since(startTime)
+	if stats != nil {
+		stats.RecordDecryptionTime(duration)
+	}
+	decryptDurationSeconds.Observe(duration.Seconds())
 	return plaintext, nil
 }
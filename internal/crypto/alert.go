@@ -0,0 +1,62 @@
+package crypto
+
+// Уровни и коды сигналов тревоги, передаваемых пиру вместо аварийного
+// завершения процесса. Сформированы по образцу TLS alert protocol.
+const (
+	AlertLevelWarning uint8 = 1
+	AlertLevelFatal   uint8 = 2
+)
+
+const (
+	AlertBadRecordMAC     uint8 = 20
+	AlertHandshakeFailure uint8 = 40
+	AlertDecryptError     uint8 = 51
+	AlertBadSignature     uint8 = 52
+	AlertReplayDetected   uint8 = 53
+	AlertUnknownCA        uint8 = 54
+	AlertProtocolVersion  uint8 = 70
+	AlertInternalError    uint8 = 80
+)
+
+// Alert — типизированное сообщение о протокольной или криптографической
+// ошибке, которое отправляется пиру вместо молчаливого разрыва соединения.
+type Alert struct {
+	Level uint8
+	Code  uint8
+	Msg   string
+}
+
+// NewAlert создает Alert с заданным уровнем, кодом и человекочитаемым сообщением.
+func NewAlert(level, code uint8, msg string) Alert {
+	return Alert{Level: level, Code: code, Msg: msg}
+}
+
+// IsFatal сообщает, должно ли получение этого предупреждения приводить к закрытию соединения.
+func (a Alert) IsFatal() bool {
+	return a.Level == AlertLevelFatal
+}
+
+// Frame — конверт передачи данных по соединению, позволяющий различать
+// обычные сообщения, alert-кадры и служебные кадры управления ключами
+// на одном и том же gob-потоке.
+type Frame struct {
+	Type      string // "message", "alert" или "keyupdate"
+	Message   *Message
+	Alert     *Alert
+	KeyUpdate *KeyUpdate
+}
+
+// NewMessageFrame оборачивает Message в Frame для передачи по соединению.
+func NewMessageFrame(msg Message) Frame {
+	return Frame{Type: "message", Message: &msg}
+}
+
+// NewAlertFrame оборачивает Alert в Frame для передачи по соединению.
+func NewAlertFrame(alert Alert) Frame {
+	return Frame{Type: "alert", Alert: &alert}
+}
+
+// NewKeyUpdateFrame оборачивает KeyUpdate в Frame для передачи по соединению.
+func NewKeyUpdateFrame(update KeyUpdate) Frame {
+	return Frame{Type: "keyupdate", KeyUpdate: &update}
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var nonceBucketName = []byte("nonces")
+
+// BoltNonceTracker — реализация NonceTracker поверх embedded key-value
+// хранилища bbolt, переживающего перезапуск процесса в пределах одного
+// узла — в отличие от MemoryNonceTracker (теряет состояние при рестарте) и
+// не требует отдельного сервиса, в отличие от RedisNonceTracker.
+type BoltNonceTracker struct {
+	db *bbolt.DB
+}
+
+// NewBoltNonceTracker открывает (создавая при необходимости) файл bbolt по
+// пути path и заводит в нем bucket для хранения записей nonce.
+func NewBoltNonceTracker(path string) (*BoltNonceTracker, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BoltNonceTracker: ошибка открытия %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(nonceBucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("BoltNonceTracker: ошибка создания bucket: %w", err)
+	}
+	return &BoltNonceTracker{db: db}, nil
+}
+
+// AddNonce хранит nonce -> момент истечения (RFC3339Nano) в bucket; запись
+// с истекшим моментом не считается дубликатом и перезаписывается — так же,
+// как в MemoryNonceTracker.AddNonce.
+func (bt *BoltNonceTracker) AddNonce(nonce []byte, ttl time.Duration) error {
+	now := time.Now()
+	return bt.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(nonceBucketName)
+		if existing := b.Get(nonce); existing != nil {
+			if expiresAt, err := time.Parse(time.RFC3339Nano, string(existing)); err == nil && now.Before(expiresAt) {
+				return errors.New("nonce уже использован (replay attack обнаружен)")
+			}
+		}
+		return b.Put(nonce, []byte(now.Add(ttl).Format(time.RFC3339Nano)))
+	})
+}
+
+// Count возвращает количество ключей в bucket (включая уже истекшие —
+// bbolt не обеспечивает фоновой очистки по TTL, в отличие от Redis).
+func (bt *BoltNonceTracker) Count() int {
+	count := 0
+	bt.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(nonceBucketName).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Reset пересоздает bucket, удаляя все записи.
+func (bt *BoltNonceTracker) Reset() {
+	bt.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(nonceBucketName); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(nonceBucketName)
+		return err
+	})
+}
+
+// Stop закрывает файл базы данных.
+func (bt *BoltNonceTracker) Stop() {
+	bt.db.Close()
+}
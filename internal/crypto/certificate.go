@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+// IdentityCertDuration — срок действия самоподписанного сертификата идентичности,
+// по истечении которого exchangeKeys должен отвергать его как просроченный.
+const IdentityCertDuration = 24 * time.Hour
+
+// IdentityCertificate — минимальный самоподписанный сертификат, связывающий
+// долговременный RSA-ключ (subject) с ECDSA-ключом того же участника (в DER),
+// чтобы MITM не мог переставить чужие ключи обмена под видом легитимного
+// RSA-ключа. Публичный ключ KEM (X25519) в сертификат не входит.
+type IdentityCertificate struct {
+	Subject      string
+	ECDSAPubDER  []byte
+	RSAPubDER    []byte
+	NotBefore    int64
+	NotAfter     int64
+	Nonce        []byte
+	RSASignature []byte // PSS-подпись долговременным RSA-ключом над canonicalBytes(Subject..Nonce)
+}
+
+// canonicalBytes сериализует поля сертификата в детерминированном порядке
+// для подписи и проверки (без учета RSASignature).
+func (c *IdentityCertificate) canonicalBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(c.Subject)
+	buf.Write(c.ECDSAPubDER)
+	buf.Write(c.RSAPubDER)
+	var tsBuf [8]byte
+	binary.BigEndian.PutUint64(tsBuf[:], uint64(c.NotBefore))
+	buf.Write(tsBuf[:])
+	binary.BigEndian.PutUint64(tsBuf[:], uint64(c.NotAfter))
+	buf.Write(tsBuf[:])
+	buf.Write(c.Nonce)
+	return buf.Bytes()
+}
+
+// NewIdentityCertificate создает и подписывает сертификат идентичности,
+// связывающий долговременный RSA-ключ subject-а с его ECDSA-ключом
+// (ecdsaPubDER). Подпись выполняется RSA-PSS поверх canonicalBytes.
+func NewIdentityCertificate(rsaPriv *rsa.PrivateKey, subject string, ecdsaPubDER, rsaPubDER []byte, validity time.Duration, stats *metrics.SecurityStats) (*IdentityCertificate, error) {
+	nonce := make([]byte, 16)
+	if _, err := DefaultRand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now()
+	cert := &IdentityCertificate{
+		Subject:     subject,
+		ECDSAPubDER: ecdsaPubDER,
+		RSAPubDER:   rsaPubDER,
+		NotBefore:   notBefore.Unix(),
+		NotAfter:    notBefore.Add(validity).Unix(),
+		Nonce:       nonce,
+	}
+
+	sig, err := SignRSAPSS(rsaPriv, cert.canonicalBytes(), stats)
+	if err != nil {
+		return nil, err
+	}
+	cert.RSASignature = sig
+	return cert, nil
+}
+
+// VerifyIdentityCertificate проверяет подпись и срок действия сертификата.
+// rsaPubDER должен совпадать с тем, что прислал пир отдельно в ходе обмена
+// ключами — иначе сертификат сам по себе ничего не доказывает.
+func VerifyIdentityCertificate(cert *IdentityCertificate, stats *metrics.SecurityStats) error {
+	now := time.Now().Unix()
+	if now < cert.NotBefore || now > cert.NotAfter {
+		return errors.New("сертификат идентичности просрочен или еще не действителен")
+	}
+	if !VerifyRSAPSS(cert.RSAPubDER, cert.canonicalBytes(), cert.RSASignature, stats) {
+		return errors.New("недействительная подпись сертификата идентичности")
+	}
+	return nil
+}
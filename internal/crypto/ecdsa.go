@@ -3,10 +3,10 @@ package crypto
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/json"
+	"io"
 	"log"
 	"math/big"
 	"time"
@@ -18,7 +18,7 @@ import (
 func SignECDSA(priv *ecdsa.PrivateKey, data []byte, stats *metrics.SecurityStats) []byte {
 	startTime := time.Now()
 	h := sha512.Sum512(data)
-	r, s, err := ecdsa.Sign(rand.Reader, priv, h[:])
+	r, s, err := ecdsa.Sign(DefaultRand, priv, h[:])
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -55,9 +55,30 @@ func VerifyECDSA(pubBytes, data, sig []byte, stats *metrics.SecurityStats) bool
 	return valid
 }
 
-// GenerateECDHKeys генерирует пару ключей ECDSA для обмена ключами ECDH
+// GenerateECDHKeys генерирует пару ключей ECDSA для обмена ключами ECDH,
+// используя DefaultRand в качестве источника энтропии.
 func GenerateECDHKeys() (*ecdsa.PrivateKey, []byte) {
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return GenerateECDHKeysWithRand(DefaultRand)
+}
+
+// GenerateECDHKeysWithRand генерирует пару ключей ECDSA, читая энтропию из
+// переданного rnd вместо DefaultRand — позволяет внедрить детерминированный
+// источник (см. NewDeterministicReader) для воспроизводимых тестов.
+func GenerateECDHKeysWithRand(rnd io.Reader) (*ecdsa.PrivateKey, []byte) {
+	return GenerateECDSAKeysWithCurveAndRand(elliptic.P256(), rnd)
+}
+
+// GenerateECDSAKeysWithCurve генерирует пару ключей ECDSA на заданной кривой
+// (elliptic.P256/P384/P521), используя DefaultRand в качестве источника
+// энтропии. GenerateECDHKeys — частный случай с фиксированной P256.
+func GenerateECDSAKeysWithCurve(curve elliptic.Curve) (*ecdsa.PrivateKey, []byte) {
+	return GenerateECDSAKeysWithCurveAndRand(curve, DefaultRand)
+}
+
+// GenerateECDSAKeysWithCurveAndRand генерирует пару ключей ECDSA на заданной
+// кривой, читая энтропию из переданного rnd вместо DefaultRand.
+func GenerateECDSAKeysWithCurveAndRand(curve elliptic.Curve, rnd io.Reader) (*ecdsa.PrivateKey, []byte) {
+	priv, err := ecdsa.GenerateKey(curve, rnd)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+var errEd25519InvalidPublicKey = errors.New("Ed25519: неверный открытый ключ")
+
+// GenerateEd25519Keys генерирует пару ключей Ed25519, используя DefaultRand
+// в качестве источника энтропии.
+func GenerateEd25519Keys() (ed25519.PrivateKey, []byte) {
+	return GenerateEd25519KeysWithRand(DefaultRand)
+}
+
+// GenerateEd25519KeysWithRand генерирует пару ключей Ed25519, читая энтропию
+// из переданного rnd вместо DefaultRand — позволяет внедрить
+// детерминированный источник (см. NewDeterministicReader) для
+// воспроизводимых тестов.
+func GenerateEd25519KeysWithRand(rnd io.Reader) (ed25519.PrivateKey, []byte) {
+	pub, priv, err := ed25519.GenerateKey(rnd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return priv, pubBytes
+}
+
+// SignEd25519 создает подпись Ed25519 для данных. В отличие от
+// SignECDSA/SignRSA, Ed25519 подписывает данные напрямую (без
+// предварительного хэширования вызывающей стороной — PureEdDSA хэширует
+// внутри себя).
+func SignEd25519(priv ed25519.PrivateKey, data []byte, stats *metrics.SecurityStats) []byte {
+	startTime := time.Now()
+	signature := ed25519.Sign(priv, data)
+	stats.RecordSigningTime(time.Since(startTime))
+	return signature
+}
+
+// VerifyEd25519 проверяет подпись Ed25519.
+func VerifyEd25519(pubBytes, data, sig []byte, stats *metrics.SecurityStats) bool {
+	startTime := time.Now()
+	pubIface, err := x509.ParsePKIXPublicKey(pubBytes)
+	if err != nil {
+		log.Println("Ошибка при разборе открытого ключа Ed25519:", err)
+		return false
+	}
+	pub, ok := pubIface.(ed25519.PublicKey)
+	if !ok {
+		log.Println(errEd25519InvalidPublicKey)
+		return false
+	}
+	valid := ed25519.Verify(pub, data, sig)
+	stats.RecordVerificationTime(time.Since(startTime))
+	return valid
+}
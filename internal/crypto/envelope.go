@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"errors"
+	"io"
+
+	"client-server/tests/metrics"
+)
+
+var (
+	errInvalidSealAlgorithm = errors.New("envelope: неизвестный алгоритм запечатывания DEK")
+	errSealedDEKTampered    = errors.New("envelope: MAC запечатанного DEK не совпадает")
+)
+
+// DEKSize — размер data encryption key (DEK), генерируемого заново для
+// каждого сообщения в схеме envelope-шифрования.
+const DEKSize = 32
+
+// sealAlgorithmAESCBCHMAC — идентификатор алгоритма запечатывания DEK,
+// хранимый в SealedDEK.SealAlgorithm. Единственная поддерживаемая на
+// сегодняшний день схема — AES-256-CBC поверх wrapping-ключа с отдельным
+// HMAC-SHA256 поверх (SealedKey ‖ IV ‖ SealAlgorithm), по аналогии с
+// метаданными SSE-C в S3 (SSESealAlgorithm/SSEIV/SSECSealedKey).
+const sealAlgorithmAESCBCHMAC = "AES-256-CBC+HMAC-SHA256"
+
+// SealedDEK хранит DEK, "запечатанный" (зашифрованный и аутентифицированный)
+// долгоживущим общим секретом сессии — тройка метаданных SealAlgorithm/IV/
+// SealedKey плюс MAC, привязывающий все три поля друг к другу.
+type SealedDEK struct {
+	SealAlgorithm string // идентификатор схемы запечатывания (см. sealAlgorithmAESCBCHMAC)
+	IV            []byte // IV AES-CBC, использованный при запечатывании DEK
+	SealedKey     []byte // DEK, зашифрованный wrapping-ключом
+	MAC           []byte // HMAC-SHA256 поверх (SealedKey ‖ IV ‖ SealAlgorithm)
+}
+
+// GenerateDEK генерирует случайный DEK размера DEKSize, используя DefaultRand
+// в качестве источника энтропии.
+func GenerateDEK() ([]byte, error) {
+	return GenerateDEKWithRand(DefaultRand)
+}
+
+// GenerateDEKWithRand генерирует DEK, читая энтропию из переданного rnd
+// вместо DefaultRand — позволяет внедрить детерминированный источник (см.
+// NewDeterministicReader) для воспроизводимых тестов.
+func GenerateDEKWithRand(rnd io.Reader) ([]byte, error) {
+	dek := make([]byte, DEKSize)
+	if _, err := io.ReadFull(rnd, dek); err != nil {
+		return nil, err
+	}
+	return dek, nil
+}
+
+// DeriveDEKSubkeys выводит из DEK раздельные ключи шифрования полезной
+// нагрузки и HMAC-аутентификации через HKDF-SHA256 с раздельными
+// info-строками — DEK сам по себе никогда не используется напрямую как
+// ключ шифрования. Экспортирована намеренно: деривация публична (по
+// принципу Керкгоффса), так что держатель скомпрометированного DEK способен
+// вывести те же подключи самостоятельно — это и демонстрирует
+// TestKeyCompromiseResilience.
+func DeriveDEKSubkeys(dek []byte) (encKey, macKey []byte, err error) {
+	encKey, err = hkdfExpand(dek, "dek-enc", AESKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, err = hkdfExpand(dek, "dek-mac", AESKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encKey, macKey, nil
+}
+
+// SealDEK запечатывает dek долгоживущим sharedSecret: выводит wrapping-ключ
+// и MAC-ключ из sharedSecret через HKDF-SHA256, шифрует dek AES-256-CBC под
+// wrapping-ключом и вычисляет HMAC-SHA256 поверх (SealedKey ‖ IV ‖
+// SealAlgorithm), связывая все три поля метаданных вместе.
+func SealDEK(dek, sharedSecret []byte, rnd io.Reader, stats *metrics.SecurityStats) (SealedDEK, error) {
+	wrapKey, err := hkdfExpand(sharedSecret, "dek-wrap-key", AESKeySize)
+	if err != nil {
+		return SealedDEK{}, err
+	}
+	macKey, err := hkdfExpand(sharedSecret, "dek-wrap-mac", AESKeySize)
+	if err != nil {
+		return SealedDEK{}, err
+	}
+
+	iv := make([]byte, 16)
+	if _, err := io.ReadFull(rnd, iv); err != nil {
+		return SealedDEK{}, err
+	}
+
+	sealedKey := AESEncrypt(wrapKey, iv, dek, stats)
+	mac := GenerateHMAC(macKey, sealMACInput(sealedKey, iv))
+
+	return SealedDEK{
+		SealAlgorithm: sealAlgorithmAESCBCHMAC,
+		IV:            iv,
+		SealedKey:     sealedKey,
+		MAC:           mac,
+	}, nil
+}
+
+// UnsealDEK распечатывает DEK, запечатанный SealDEK тем же sharedSecret.
+// MAC проверяется constant-time сравнением (VerifyHMAC) прежде, чем
+// SealedKey вообще передается в AESDecrypt — подмена любого из полей тройки
+// метаданных обнаруживается на этапе проверки MAC.
+func UnsealDEK(sealed SealedDEK, sharedSecret []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	if sealed.SealAlgorithm != sealAlgorithmAESCBCHMAC {
+		return nil, errInvalidSealAlgorithm
+	}
+
+	wrapKey, err := hkdfExpand(sharedSecret, "dek-wrap-key", AESKeySize)
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := hkdfExpand(sharedSecret, "dek-wrap-mac", AESKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !VerifyHMAC(macKey, sealMACInput(sealed.SealedKey, sealed.IV), sealed.MAC) {
+		return nil, errSealedDEKTampered
+	}
+
+	return AESDecrypt(wrapKey, sealed.IV, sealed.SealedKey, stats)
+}
+
+// sealMACInput собирает (SealedKey ‖ IV ‖ SealAlgorithm) — вход HMAC,
+// связывающий все поля метаданных SealedDEK друг с другом.
+func sealMACInput(sealedKey, iv []byte) []byte {
+	input := make([]byte, 0, len(sealedKey)+len(iv)+len(sealAlgorithmAESCBCHMAC))
+	input = append(input, sealedKey...)
+	input = append(input, iv...)
+	input = append(input, []byte(sealAlgorithmAESCBCHMAC)...)
+	return input
+}
@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KEM описывает механизм согласования общего секрета (key encapsulation mechanism),
+// позволяя выбирать алгоритм обмена ключами независимо от остального протокола.
+type KEM interface {
+	// GenerateKeyPair генерирует новую пару ключей и возвращает публичный ключ для передачи пиру.
+	GenerateKeyPair() ([]byte, error)
+	// DeriveShared вычисляет общий секрет на основе публичного ключа пира.
+	DeriveShared(peerPub []byte) ([]byte, error)
+	// Algo возвращает идентификатор алгоритма для согласования в handshake.
+	Algo() string
+}
+
+// P256KEM реализует KEM поверх существующего ECDH на кривой P-256.
+type P256KEM struct {
+	priv *ecdsa.PrivateKey
+	pub  []byte
+}
+
+// NewP256KEM создает KEM на базе P-256 ECDH.
+func NewP256KEM() *P256KEM {
+	return &P256KEM{}
+}
+
+func (k *P256KEM) GenerateKeyPair() ([]byte, error) {
+	priv, pub := GenerateECDHKeys()
+	k.priv = priv
+	k.pub = pub
+	return pub, nil
+}
+
+// DeriveShared вычисляет сырой общий секрет ECDH и пропускает его через
+// deriveSessionKey с той же солью-транскриптом, что и X25519KEM.DeriveShared
+// — так оба алгоритма отдают общий секрет одинаковой формы
+// (AESKeySize+HMACKeySize байт), а не сырой 32-байтовый хэш для P-256 против
+// HKDF-расширенного результата для X25519.
+func (k *P256KEM) DeriveShared(peerPub []byte) ([]byte, error) {
+	if k.priv == nil {
+		return nil, errors.New("P256KEM: ключевая пара еще не сгенерирована")
+	}
+	raw := ComputeSharedSecret(k.priv, peerPub)
+	return deriveSessionKey(raw, kemTranscriptSalt(k.pub, peerPub))
+}
+
+func (k *P256KEM) Algo() string {
+	return "P-256"
+}
+
+// X25519KEM реализует KEM поверх Curve25519 (X25519).
+type X25519KEM struct {
+	priv [32]byte
+	pub  [32]byte
+}
+
+// NewX25519KEM создает KEM на базе X25519.
+func NewX25519KEM() *X25519KEM {
+	return &X25519KEM{}
+}
+
+// GenerateX25519KeyPair генерирует пару ключей X25519 согласно стандартному
+// clamp-and-Montgomery-ladder алгоритму, используя DefaultRand в качестве
+// источника энтропии, и возвращает (приватный, публичный) ключи.
+func GenerateX25519KeyPair() (priv, pub [32]byte, err error) {
+	return GenerateX25519KeyPairWithRand(DefaultRand)
+}
+
+// GenerateX25519KeyPairWithRand генерирует пару ключей X25519, читая энтропию
+// из переданного rnd вместо DefaultRand — позволяет внедрить детерминированный
+// источник (см. NewDeterministicReader) для воспроизводимых тестов.
+func GenerateX25519KeyPairWithRand(rnd io.Reader) (priv, pub [32]byte, err error) {
+	if _, err = io.ReadFull(rnd, priv[:]); err != nil {
+		return priv, pub, err
+	}
+	clampX25519Scalar(&priv)
+	pubSlice, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	if err != nil {
+		return priv, pub, err
+	}
+	copy(pub[:], pubSlice)
+	return priv, pub, nil
+}
+
+// clampX25519Scalar применяет стандартное преобразование приватного скаляра X25519.
+func clampX25519Scalar(priv *[32]byte) {
+	priv[0] &= 248
+	priv[31] &= 127
+	priv[31] |= 64
+}
+
+// ComputeX25519SharedSecret вычисляет общий секрет X25519 между собственной
+// парой (ownPub — публичный ключ, соответствующий priv) и peerPub, отклоняет
+// вырожденный (нулевой) результат, защищаясь от атак на малую подгруппу, и
+// выводит из него сессионный ключ HKDF-SHA256 с солью, построенной из обоих
+// публичных ключей (см. kemTranscriptSalt) — соль одинакова на обеих
+// сторонах независимо от роли, поэтому обе стороны получают один и тот же
+// SharedSecret, а не расходящиеся независимо выбранные соли.
+func ComputeX25519SharedSecret(priv, ownPub, peerPub [32]byte) ([]byte, error) {
+	shared, err := curve25519.X25519(priv[:], peerPub[:])
+	if err != nil {
+		return nil, err
+	}
+	var zero [32]byte
+	if subtleEqual(shared, zero[:]) {
+		return nil, errors.New("X25519: вычислен вырожденный общий секрет (all-zero)")
+	}
+	return deriveSessionKey(shared, kemTranscriptSalt(ownPub[:], peerPub[:]))
+}
+
+// subtleEqual сравнивает два среза байт без короткого замыкания по времени.
+func subtleEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var v byte
+	for i := range a {
+		v |= a[i] ^ b[i]
+	}
+	return v == 0
+}
+
+// deriveSessionKey пропускает сырой общий секрет через HKDF-SHA256 с солью
+// salt, чтобы результат KEM можно было напрямую использовать как ключ
+// AES+HMAC. salt должна быть согласована обеими сторонами (см.
+// kemTranscriptSalt) — случайная, выбранная независимо каждой стороной соль
+// приводила бы к тому, что стороны вычисляют разные SharedSecret и не могут
+// расшифровать сообщения друг друга.
+func deriveSessionKey(raw, salt []byte) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, raw, salt, []byte("x25519 shared secret"))
+	out := make([]byte, AESKeySize+HMACKeySize)
+	if _, err := kdf.Read(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// kemTranscriptSalt строит соль HKDF, общую для обеих сторон key agreement,
+// из публичных ключей, которыми они обменялись: конкатенация
+// лексикографически меньшего и большего среза байт, хэшированная SHA-256.
+// Сортировка по значению (а не по роли "клиент"/"сервер") гарантирует, что
+// обе стороны — зная только свой и чужой публичный ключ — вычислят одну и ту
+// же соль, не согласовывая отдельно, кто есть кто.
+func kemTranscriptSalt(pubA, pubB []byte) []byte {
+	first, second := pubA, pubB
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	h := sha256.New()
+	h.Write(first)
+	h.Write(second)
+	return h.Sum(nil)
+}
+
+func (k *X25519KEM) GenerateKeyPair() ([]byte, error) {
+	priv, pub, err := GenerateX25519KeyPair()
+	if err != nil {
+		return nil, err
+	}
+	k.priv = priv
+	k.pub = pub
+	return pub[:], nil
+}
+
+func (k *X25519KEM) DeriveShared(peerPub []byte) ([]byte, error) {
+	if len(peerPub) != 32 {
+		return nil, errors.New("X25519KEM: некорректная длина публичного ключа пира")
+	}
+	var peer [32]byte
+	copy(peer[:], peerPub)
+	return ComputeX25519SharedSecret(k.priv, k.pub, peer)
+}
+
+func (k *X25519KEM) Algo() string {
+	return "X25519"
+}
+
+// NewKEM создает KEM по текстовому идентификатору алгоритма, согласованному в handshake.
+func NewKEM(algo string) (KEM, error) {
+	switch algo {
+	case "X25519":
+		return NewX25519KEM(), nil
+	case "P-256", "":
+		return NewP256KEM(), nil
+	default:
+		return nil, errors.New("неизвестный алгоритм KEM: " + algo)
+	}
+}
@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+
+	"client-server/tests/metrics"
+)
+
+// Algorithm — идентификатор семейства асимметричного примитива, используемый
+// AlgorithmProfile/GenerateKeyPair. В отличие от SigAlgo (который различает
+// схему подписи, например PKCS1v15 против PSS для одного и того же RSA),
+// Algorithm различает сами примитивы — нужен там, где варьируется не схема, а
+// сам алгоритм и его параметры (размер ключа RSA, кривая ECDSA).
+type Algorithm string
+
+const (
+	AlgorithmRSA     Algorithm = "RSA"
+	AlgorithmECDSA   Algorithm = "ECDSA"
+	AlgorithmEd25519 Algorithm = "Ed25519"
+	// AlgorithmHybridPQ — гибридная подпись Dilithium3+Ed25519 (см.
+	// GenerateHybridSignKeyPair/HybridSign/HybridVerify в pqhybrid.go).
+	// В отличие от остальных значений Algorithm, GenerateKeyPair для этой
+	// ячейки не использует RSABits/Curve.
+	AlgorithmHybridPQ Algorithm = "HybridPQ"
+)
+
+// AlgorithmProfile описывает одну ячейку матрицы асимметричных примитивов:
+// алгоритм и его параметры (RSABits — только для AlgorithmRSA, Curve —
+// только для AlgorithmECDSA). Используется GenerateKeyPair и встраивается в
+// benchmark.PerformanceProfile/benchmark.ClientProfile, чтобы нагрузочные
+// тесты и бенчмарки могли перебирать алгоритмы так же, как они уже перебирают
+// типы клиентов и размеры сообщений.
+//
+// Брейнпул-кривые (P-256/P-384/P-512), упомянутые в исходном запросе,
+// сознательно не реализованы: crypto/elliptic стандартной библиотеки
+// поддерживает только кривые NIST (P224/P256/P384/P521), а добавление
+// стороннего пакета ради параметров кривой ради одних лишь бенчмарков не
+// оправдано — см. AlgorithmProfiles, где перечислены только реально
+// поддерживаемые ячейки матрицы.
+type AlgorithmProfile struct {
+	Algorithm Algorithm
+	Curve     elliptic.Curve
+	RSABits   int
+}
+
+// String возвращает краткое имя ячейки матрицы, например "RSA-2048" или
+// "ECDSA-P384" — используется как ключ LoadTestResult/MatrixKey и в выводе
+// CompareClientPerformanceMatrix.
+func (p AlgorithmProfile) String() string {
+	switch p.Algorithm {
+	case AlgorithmRSA:
+		return fmt.Sprintf("RSA-%d", p.RSABits)
+	case AlgorithmECDSA:
+		return fmt.Sprintf("ECDSA-%s", curveName(p.Curve))
+	case AlgorithmEd25519:
+		return "Ed25519"
+	case AlgorithmHybridPQ:
+		return "Dilithium3+Ed25519"
+	default:
+		return "неизвестный AlgorithmProfile"
+	}
+}
+
+func curveName(curve elliptic.Curve) string {
+	if curve == nil {
+		return "?"
+	}
+	return curve.Params().Name
+}
+
+// AlgorithmProfiles возвращает полную матрицу асимметричных примитивов,
+// перебираемую нагрузочными тестами и бенчмарками: RSA-1024/2048/3072/4096,
+// ECDSA на P-256/P-384/P-521 и Ed25519.
+func AlgorithmProfiles() []AlgorithmProfile {
+	profiles := []AlgorithmProfile{
+		{Algorithm: AlgorithmEd25519},
+	}
+	for _, bits := range []int{1024, 2048, 3072, 4096} {
+		profiles = append(profiles, AlgorithmProfile{Algorithm: AlgorithmRSA, RSABits: bits})
+	}
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		profiles = append(profiles, AlgorithmProfile{Algorithm: AlgorithmECDSA, Curve: curve})
+	}
+	return profiles
+}
+
+// KeyPair — результат GenerateKeyPair: Private хранит конкретный приватный
+// ключ (*rsa.PrivateKey, *ecdsa.PrivateKey или ed25519.PrivateKey), Public —
+// его PKIX-кодирование, как и у остальных Generate*Keys в этом пакете. Для
+// AlgorithmHybridPQ Private хранит *HybridSignKeyPair, а Public пуст.
+type KeyPair struct {
+	Private interface{}
+	Public  []byte
+}
+
+// GenerateKeyPair генерирует пару ключей согласно profile, диспетчеризуя в
+// существующие GenerateRSAKeysWithSize/GenerateECDSAKeysWithCurve/
+// GenerateEd25519Keys — не дублирует их логику.
+func GenerateKeyPair(profile AlgorithmProfile) (KeyPair, error) {
+	switch profile.Algorithm {
+	case AlgorithmRSA:
+		bits := profile.RSABits
+		if bits == 0 {
+			bits = RSAKeySize
+		}
+		priv, pub := GenerateRSAKeysWithSize(bits)
+		return KeyPair{Private: priv, Public: pub}, nil
+	case AlgorithmECDSA:
+		curve := profile.Curve
+		if curve == nil {
+			curve = elliptic.P256()
+		}
+		priv, pub := GenerateECDSAKeysWithCurve(curve)
+		return KeyPair{Private: priv, Public: pub}, nil
+	case AlgorithmEd25519:
+		priv, pub := GenerateEd25519Keys()
+		return KeyPair{Private: priv, Public: pub}, nil
+	case AlgorithmHybridPQ:
+		hybrid, err := GenerateHybridSignKeyPair()
+		if err != nil {
+			return KeyPair{}, err
+		}
+		// Public не заполняется: HybridVerify проверяет по самому hybrid
+		// (Dilithium3PublicKey/Ed25519PublicKey уже встроены в него), в
+		// отличие от RSA/ECDSA/Ed25519, где публичный ключ кодируется
+		// отдельно в PKIX.
+		return KeyPair{Private: hybrid}, nil
+	default:
+		return KeyPair{}, fmt.Errorf("GenerateKeyPair: неизвестный Algorithm: %q", profile.Algorithm)
+	}
+}
+
+// SignWithKeyPair подписывает data приватным ключом kp, выбирая реализацию
+// подписи (SignRSA/SignECDSA/SignEd25519) по фактическому типу kp.Private.
+func SignWithKeyPair(kp KeyPair, data []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	switch priv := kp.Private.(type) {
+	case *rsa.PrivateKey:
+		return SignRSA(priv, data, stats), nil
+	case *ecdsa.PrivateKey:
+		return SignECDSA(priv, data, stats), nil
+	case ed25519.PrivateKey:
+		return SignEd25519(priv, data, stats), nil
+	case *HybridSignKeyPair:
+		return HybridSign(priv, data, stats), nil
+	default:
+		return nil, fmt.Errorf("SignWithKeyPair: неподдерживаемый тип приватного ключа %T", kp.Private)
+	}
+}
+
+// VerifyWithKeyPair проверяет подпись sig над data открытым ключом kp.Public,
+// выбирая реализацию проверки по фактическому типу kp.Private (сгенерирована
+// той же GenerateKeyPair, так что типы Private/Public всегда согласованы).
+func VerifyWithKeyPair(kp KeyPair, data, sig []byte, stats *metrics.SecurityStats) bool {
+	switch kp.Private.(type) {
+	case *rsa.PrivateKey:
+		return VerifyRSA(kp.Public, data, sig, stats)
+	case *ecdsa.PrivateKey:
+		return VerifyECDSA(kp.Public, data, sig, stats)
+	case ed25519.PrivateKey:
+		return VerifyEd25519(kp.Public, data, sig, stats)
+	case *HybridSignKeyPair:
+		return HybridVerify(kp.Private.(*HybridSignKeyPair), data, sig, stats)
+	default:
+		return false
+	}
+}
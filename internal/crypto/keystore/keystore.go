@@ -0,0 +1,246 @@
+// Package keystore реализует защищенное паролем хранение долгосрочных
+// идентификационных ключей (RSA и ECDSA) на диске.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrBadPassphrase возвращается, когда пароль неверен или файл поврежден.
+// Намеренно не различает эти два случая ни в тексте ошибки, ни по времени
+// выполнения, чтобы не давать атакующему оракул для подбора пароля.
+var ErrBadPassphrase = errors.New("keystore: неверный пароль или поврежденный файл идентичности")
+
+const fileVersion = 1
+
+// Argon2id параметры по умолчанию, подобранные так, чтобы расшифровка
+// занимала ориентировочно ~250 мс на обычном ноутбуке.
+const (
+	DefaultArgonTime    = 3
+	DefaultArgonMemory  = 64 * 1024 // KiB
+	DefaultArgonThreads = 4
+	argonKeyLen         = 32
+	saltSize            = 16
+	nonceSize           = 12
+)
+
+// Identity хранит долгосрочную пару ключей, используемую для установления соединений.
+type Identity struct {
+	ECDSAPrivate *ecdsa.PrivateKey
+	ECDSAPublic  []byte
+	RSAPrivate   *rsa.PrivateKey
+	RSAPublic    []byte
+}
+
+// identityBlob — то, что реально маршалится в JSON перед шифрованием.
+type identityBlob struct {
+	ECDSAPrivateDER []byte `json:"ecdsa_private_der"`
+	RSAPrivateDER   []byte `json:"rsa_private_der"`
+}
+
+// argonParams описывает параметры KDF, зафиксированные в файле для воспроизводимости.
+type argonParams struct {
+	Time        uint32 `json:"time"`
+	MemoryKiB   uint32 `json:"memory_kib"`
+	Parallelism uint8  `json:"parallelism"`
+	KeyLen      uint32 `json:"key_len"`
+	Salt        []byte `json:"salt"`
+}
+
+// identityFile — версионированный формат файла на диске.
+type identityFile struct {
+	Version    int         `json:"version"`
+	Argon2     argonParams `json:"argon2"`
+	Nonce      []byte      `json:"nonce"`
+	Ciphertext []byte      `json:"ciphertext"`
+}
+
+// SaveIdentity сериализует идентификационные ключи, шифрует их AES-256-GCM под
+// ключом, выведенным Argon2id из passphrase, и записывает результат в path.
+func SaveIdentity(path string, passphrase []byte, id *Identity) error {
+	ecdsaDER, err := x509.MarshalECPrivateKey(id.ECDSAPrivate)
+	if err != nil {
+		return err
+	}
+	rsaDER := x509.MarshalPKCS1PrivateKey(id.RSAPrivate)
+
+	plaintext, err := json.Marshal(identityBlob{
+		ECDSAPrivateDER: ecdsaDER,
+		RSAPrivateDER:   rsaDER,
+	})
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	params := argonParams{
+		Time:        DefaultArgonTime,
+		MemoryKiB:   DefaultArgonMemory,
+		Parallelism: DefaultArgonThreads,
+		KeyLen:      argonKeyLen,
+		Salt:        salt,
+	}
+	key := deriveKey(passphrase, params)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	file := identityFile{
+		Version:    fileVersion,
+		Argon2:     params,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// LoadIdentity читает path, выводит ключ Argon2id из passphrase и
+// расшифровывает хранящуюся идентичность. Любая ошибка разбора или
+// расшифровки — от несовпадения GCM-тега до некорректного JSON —
+// возвращается как ErrBadPassphrase, чтобы не раскрывать, что именно не так
+// с файлом; ошибка чтения самого файла возвращается как есть.
+func LoadIdentity(path string, passphrase []byte) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file identityFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != fileVersion {
+		// Argon2id выполняется и здесь, с параметрами по умолчанию, чтобы
+		// неразбираемый файл не отличался по времени от неверного пароля.
+		deriveKey(passphrase, argonParams{
+			Time:        DefaultArgonTime,
+			MemoryKiB:   DefaultArgonMemory,
+			Parallelism: DefaultArgonThreads,
+			KeyLen:      argonKeyLen,
+			Salt:        make([]byte, saltSize),
+		})
+		return nil, ErrBadPassphrase
+	}
+
+	key := deriveKey(passphrase, file.Argon2)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	var blob identityBlob
+	if err := json.Unmarshal(plaintext, &blob); err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	ecdsaPriv, err := x509.ParseECPrivateKey(blob.ECDSAPrivateDER)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	rsaPriv, err := x509.ParsePKCS1PrivateKey(blob.RSAPrivateDER)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	ecdsaPub, err := x509.MarshalPKIXPublicKey(&ecdsaPriv.PublicKey)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	return &Identity{
+		ECDSAPrivate: ecdsaPriv,
+		ECDSAPublic:  ecdsaPub,
+		RSAPrivate:   rsaPriv,
+		RSAPublic:    rsaPub,
+	}, nil
+}
+
+// deriveKey выводит 32-байтовый ключ AES-256 из пароля и параметров Argon2id.
+func deriveKey(passphrase []byte, params argonParams) []byte {
+	return argon2.IDKey(passphrase, params.Salt, params.Time, params.MemoryKiB, params.Parallelism, params.KeyLen)
+}
+
+// LoadOrCreate загружает идентичность из path, если файл уже существует,
+// иначе вызывает generate, сохраняет результат под переданным passphrase и
+// возвращает его. Это избавляет операторов от необходимости перегенерировать
+// ключи при каждом запуске процесса (и позволяет воспроизводимо тестировать
+// защиту от replay/timing атак между перезапусками).
+func LoadOrCreate(path string, passphrase []byte, generate func() *Identity) (*Identity, error) {
+	if _, err := os.Stat(path); err == nil {
+		return LoadIdentity(path, passphrase)
+	}
+
+	id := generate()
+	if err := SaveIdentity(path, passphrase, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// CalibrateArgonParams измеряет время вычисления Argon2id на текущем хосте
+// при фиксированных memory/parallelism и подбирает time-параметр так, чтобы
+// суммарная стоимость деривации приблизилась к targetCost снизу (не тратя
+// больше одной лишней итерации time на перебор).
+func CalibrateArgonParams(targetCost time.Duration) argonParams {
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+
+	params := argonParams{
+		Time:        1,
+		MemoryKiB:   DefaultArgonMemory,
+		Parallelism: DefaultArgonThreads,
+		KeyLen:      argonKeyLen,
+		Salt:        salt,
+	}
+
+	start := time.Now()
+	deriveKey([]byte("calibration"), params)
+	perIteration := time.Since(start)
+	if perIteration <= 0 {
+		perIteration = time.Millisecond
+	}
+
+	iterations := uint32(targetCost / perIteration)
+	if iterations < 1 {
+		iterations = 1
+	}
+	params.Time = iterations
+	return params
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MACAlgo* — идентификаторы реализаций MAC, хранимые в Message.MACAlgo.
+// Нулевое значение (MACAlgoHMACSHA256) сохраняет поведение сообщений,
+// созданных до появления этого поля (gob декодирует отсутствующее поле как
+// нулевое значение типа).
+const (
+	MACAlgoHMACSHA256 byte = iota
+	MACAlgoBLAKE2b
+)
+
+// MAC — интерфейс подключаемого алгоритма message authentication code,
+// позволяющий CreateSecureMessage/VerifyAndDecryptMessage выбирать
+// реализацию по Message.MACAlgo вместо жестко закодированного HMAC-SHA256.
+type MAC interface {
+	Sum(key, data []byte) []byte
+	Verify(key, data, tag []byte) bool
+}
+
+// HMACSHA256MAC — обертка над GenerateHMAC/VerifyHMAC (легаси-реализация).
+type HMACSHA256MAC struct{}
+
+func (HMACSHA256MAC) Sum(key, data []byte) []byte {
+	return GenerateHMAC(key, data)
+}
+
+func (HMACSHA256MAC) Verify(key, data, tag []byte) bool {
+	return VerifyHMAC(key, data, tag)
+}
+
+// BLAKE2bMAC — keyed BLAKE2b-256 MAC: в отличие от HMAC-SHA256 (hash поверх
+// двух раундов с внутренней/внешней прокладкой), BLAKE2b поддерживает
+// ключевание нативно на уровне самой функции сжатия, что дает более
+// короткий и быстрый MAC той же стойкости.
+type BLAKE2bMAC struct{}
+
+func (BLAKE2bMAC) Sum(key, data []byte) []byte {
+	h, err := blake2b.New256(key)
+	if err != nil {
+		// blake2b.New256 возвращает ошибку только при ключе длиннее 64 байт —
+		// все ключи в этом пакете выводятся через hkdfExpand/ECDH и имеют
+		// фиксированный размер не больше 32 байт, так что это невозможно при
+		// корректном использовании, см. аналогичный panic в AESEncrypt.
+		panic(fmt.Errorf("BLAKE2bMAC: %w", err))
+	}
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (m BLAKE2bMAC) Verify(key, data, tag []byte) bool {
+	return subtle.ConstantTimeCompare(m.Sum(key, data), tag) == 1
+}
+
+// macByAlgo возвращает реализацию MAC по идентификатору Message.MACAlgo.
+func macByAlgo(algo byte) (MAC, error) {
+	switch algo {
+	case MACAlgoHMACSHA256:
+		return HMACSHA256MAC{}, nil
+	case MACAlgoBLAKE2b:
+		return BLAKE2bMAC{}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный алгоритм MAC: %d", algo)
+	}
+}
+
+// deriveNonceObfuscationKey выводит ключ для computeNonceTag из sharedSecret
+// через HKDF-SHA256 — отдельной меткой от ключей шифрования/HMAC сообщения.
+func deriveNonceObfuscationKey(sharedSecret []byte) ([]byte, error) {
+	return hkdfExpand(sharedSecret, "nonce-obfuscation-key", 32)
+}
+
+// computeNonceTag вычисляет keyed-BLAKE2b тег поверх (Nonce ‖ Timestamp) —
+// привязывает nonce к временной метке сообщения, не давая атакующему
+// подставить чужой валидный nonce к другому timestamp без знания
+// nonceObfuscationKey. Полноценное сокрытие самого nonce на проводе
+// потребовало бы переноса этой логики на транспортный уровень (чтобы по
+// сети передавался только тег, а не сырой nonce) — здесь Message.Nonce
+// по-прежнему хранится в открытом виде для глобального дедуплицирующего
+// globalNonceCache, а NonceTag лишь дополнительно аутентифицирует его.
+func computeNonceTag(key, nonce []byte, timestamp int64) []byte {
+	var tsBytes [8]byte
+	binary.BigEndian.PutUint64(tsBytes[:], uint64(timestamp))
+	data := make([]byte, 0, len(nonce)+8)
+	data = append(data, nonce...)
+	data = append(data, tsBytes[:]...)
+	return BLAKE2bMAC{}.Sum(key, data)
+}
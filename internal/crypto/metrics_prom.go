@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Метрики Prometheus, отражающие то же состояние, что уже доступно только
+// через GetConcurrentOpsCount/RateLimitCheck — concurrentOps и отказы
+// RateLimiter были видны лишь в момент вызова, без истории во времени.
+var (
+	concurrentOpsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crypto_concurrent_ops",
+		Help: "Текущее количество параллельных криптографических операций (см. ConcurrencyCheck).",
+	})
+
+	concurrencyRejectsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crypto_concurrency_rejects_total",
+		Help: "Количество операций, отклоненных ConcurrencyCheck из-за превышения лимита параллельности.",
+	})
+
+	rateLimitRejectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_ratelimit_rejects_total",
+		Help: "Количество операций, отклоненных RateLimitCheck, по client_id.",
+	}, []string{"client_id"})
+
+	encryptDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crypto_encrypt_duration_seconds",
+		Help:    "Длительность AESEncrypt в секундах.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	decryptDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crypto_decrypt_duration_seconds",
+		Help:    "Длительность AESDecrypt в секундах.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// RegisterMetrics регистрирует все метрики пакета crypto в r. Принимает
+// prometheus.Registerer (а не сразу регистрирует их в
+// prometheus.DefaultRegisterer), чтобы тесты могли передать собственный
+// prometheus.NewRegistry() и не засорять глобальный регистр повторными
+// регистрациями между тестовыми запусками.
+func RegisterMetrics(r prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		concurrentOpsGauge,
+		concurrencyRejectsTotal,
+		rateLimitRejectsTotal,
+		encryptDurationSeconds,
+		decryptDurationSeconds,
+	}
+	for _, c := range collectors {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MetricsHandler возвращает http.Handler для монтирования на /metrics (см.
+// cmd/app/main.go) — тонкая обертка над promhttp.Handler, экспортирующая
+// prometheus.DefaultGatherer.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,310 @@
+package crypto
+
+import (
+	"container/list"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errNonceReplay = errors.New("nonce уже использован (replay attack обнаружен)")
+
+// NonceCacheConfig настраивает ShardedNonceCache: количество шардов (снижает
+// конкуренцию блокировок под параллельной нагрузкой), максимальное число
+// записей на шард (верхний предел памяти — без него накопление
+// неограниченного числа уникальных nonce само по себе является DoS-вектором,
+// см. TestNonceExhaustionAttack) и окно приема по времени, за пределами
+// которого запись считается устаревшей и вытесняется вне зависимости от
+// размера шарда. Window обычно выбирается равным окну проверки timestamp в
+// VerifyAndDecryptMessage (MaxTimeDifference) — нет смысла помнить nonce
+// дольше, чем сообщение с таким timestamp вообще может быть принято.
+type NonceCacheConfig struct {
+	ShardCount         int
+	MaxEntriesPerShard int
+	Window             time.Duration
+}
+
+// DefaultNonceCacheConfig возвращает конфигурацию на MaxNonceStorage
+// суммарных записей при 16 шардах и окне, вдвое превышающем
+// MaxTimeDifference (запас на рассинхронизацию часов сторон).
+func DefaultNonceCacheConfig() NonceCacheConfig {
+	const shardCount = 16
+	return NonceCacheConfig{
+		ShardCount:         shardCount,
+		MaxEntriesPerShard: MaxNonceStorage / shardCount,
+		Window:             time.Duration(MaxTimeDifference) * time.Second * 2,
+	}
+}
+
+// ShardedNonceCache — шардированный кэш предъявленных nonce с Bloom-фильтром
+// на быстром пути и FIFO-вытеснением по размеру шарда и по возрасту записи.
+// В отличие от MemoryNonceTracker (единая карта без верхнего предела), каждый шард
+// ограничен NonceCacheConfig.MaxEntriesPerShard записями, что дает жесткий
+// потолок памяти независимо от того, сколько уникальных nonce пытается
+// накопить атакующий.
+type ShardedNonceCache struct {
+	cfg    NonceCacheConfig
+	shards []*nonceShard
+}
+
+// NewShardedNonceCache создает кэш с заданной конфигурацией.
+func NewShardedNonceCache(cfg NonceCacheConfig) *ShardedNonceCache {
+	if cfg.ShardCount <= 0 {
+		cfg.ShardCount = 1
+	}
+	shards := make([]*nonceShard, cfg.ShardCount)
+	for i := range shards {
+		shards[i] = newNonceShard(cfg.MaxEntriesPerShard, cfg.Window)
+	}
+	return &ShardedNonceCache{cfg: cfg, shards: shards}
+}
+
+// AddNonce фиксирует использование nonce, возвращая errNonceReplay, если он
+// уже был предъявлен и еще не вытеснен из кэша.
+func (c *ShardedNonceCache) AddNonce(nonce []byte) error {
+	return c.shardFor(nonce).addNonce(nonce)
+}
+
+// Count возвращает суммарное число записей во всех шардах.
+func (c *ShardedNonceCache) Count() int {
+	total := 0
+	for _, s := range c.shards {
+		total += s.count()
+	}
+	return total
+}
+
+// EvictExpired принудительно вытесняет устаревшие записи во всех шардах —
+// используется периодической очисткой и ClearOldNonces.
+func (c *ShardedNonceCache) EvictExpired() {
+	for _, s := range c.shards {
+		s.evictExpired()
+	}
+}
+
+// Reset полностью очищает кэш во всех шардах (для тестирования).
+func (c *ShardedNonceCache) Reset() {
+	for _, s := range c.shards {
+		s.reset()
+	}
+}
+
+func (c *ShardedNonceCache) shardFor(nonce []byte) *nonceShard {
+	h := fnv.New32a()
+	h.Write(nonce)
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// nonceEntry — запись FIFO-очереди шарда: nonce и время, когда он был принят.
+type nonceEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+// nonceShard хранит часть общего пространства nonce: Bloom-фильтр для
+// lock-free быстрого пути, точную карту для медленного пути и FIFO-очередь
+// (по списку) для вытеснения по размеру и по возрасту.
+type nonceShard struct {
+	mu      sync.Mutex
+	bloom   *bloomFilter
+	order   *list.List // Front() — самая старая запись, Back() — самая новая
+	entries map[string]*list.Element
+	maxSize int
+	window  time.Duration
+}
+
+func newNonceShard(maxSize int, window time.Duration) *nonceShard {
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	return &nonceShard{
+		bloom:   newBloomFilter(nonceBloomBits(maxSize), 4),
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		maxSize: maxSize,
+		window:  window,
+	}
+}
+
+func (s *nonceShard) addNonce(nonce []byte) error {
+	key := string(nonce)
+
+	// Быстрый путь: Bloom-фильтр читается атомарно, без блокировки шарда.
+	// Если он точно отвечает "не видели", от проверки картой можно
+	// отказаться лишь до захвата мьютекса — основной объем легитимного
+	// трафика (уникальные nonce) идет этим путем, не конкурируя за мьютекс
+	// шарда на стадии самой проверки Bloom. Но под мьютексом карту все равно
+	// нужно перепроверить: два конкурентных вызова с одинаковым свежим
+	// nonce оба могут пройти lock-free проверку Bloom как "не видели" и
+	// затем оба захватить мьютекс по очереди — без повторной проверки
+	// второй вызов молча перезаписал бы запись первого в s.entries (осиротив
+	// его элемент списка) и ошибочно вернул бы nil вместо errNonceReplay.
+	if !s.bloom.MaybeContains(key) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.entries[key]; exists {
+			return errNonceReplay
+		}
+		s.bloom.Add(key)
+		s.insertLocked(key)
+		return nil
+	}
+
+	// Медленный путь: Bloom ответил "возможно видели" — это либо настоящий
+	// повтор, либо ложноположительное срабатывание, и точный ответ требует
+	// блокировки шарда и поиска в карте.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpiredLocked()
+	if _, exists := s.entries[key]; exists {
+		return errNonceReplay
+	}
+	s.bloom.Add(key)
+	s.insertLocked(key)
+	return nil
+}
+
+// insertLocked добавляет nonce в конец FIFO-очереди и вытесняет устаревшие
+// либо избыточные записи. Вызывающий код должен удерживать s.mu.
+func (s *nonceShard) insertLocked(key string) {
+	elem := s.order.PushBack(&nonceEntry{key: key, seenAt: time.Now()})
+	s.entries[key] = elem
+	s.evictExpiredLocked()
+	for s.order.Len() > s.maxSize {
+		s.evictFrontLocked()
+	}
+}
+
+// evictExpiredLocked вытесняет записи старше s.window с начала очереди —
+// запись добавляется в конец по времени прибытия, поэтому начало очереди
+// всегда самое старое.
+func (s *nonceShard) evictExpiredLocked() {
+	if s.window <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-s.window)
+	for {
+		front := s.order.Front()
+		if front == nil {
+			return
+		}
+		if front.Value.(*nonceEntry).seenAt.After(cutoff) {
+			return
+		}
+		s.evictFrontLocked()
+	}
+}
+
+func (s *nonceShard) evictFrontLocked() {
+	front := s.order.Front()
+	if front == nil {
+		return
+	}
+	entry := s.order.Remove(front).(*nonceEntry)
+	delete(s.entries, entry.key)
+}
+
+func (s *nonceShard) evictExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpiredLocked()
+}
+
+func (s *nonceShard) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *nonceShard) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.order = list.New()
+	s.entries = make(map[string]*list.Element)
+	s.bloom = newBloomFilter(nonceBloomBits(s.maxSize), s.bloom.k)
+}
+
+// bloomFilter — Bloom-фильтр без блокировок: членство проверяется и
+// обновляется атомарными операциями над битовыми словами, что позволяет
+// читать его из addNonce без захвата мьютекса шарда. k независимых позиций
+// хэша получаются двойным хэшированием Кирша-Мицнмахера поверх двух разных
+// 64-битных хэшей FNV одного и того же ключа (один проход по данным вместо
+// k отдельных хэш-функций).
+//
+// Известное ограничение: фильтр никогда не уменьшается и не перестраивается
+// при вытеснении записей из шарда, поэтому частота ложноположительных
+// срабатываний медленного пути постепенно растет на долгоживущем процессе —
+// это приемлемо, так как ложноположительное срабатывание лишь добавляет
+// один lock + поиск в карте, не влияя на корректность.
+type bloomFilter struct {
+	bits []uint64
+	k    uint
+}
+
+func newBloomFilter(numBits uint, k uint) *bloomFilter {
+	words := (numBits + 63) / 64
+	if words == 0 {
+		words = 1
+	}
+	return &bloomFilter{bits: make([]uint64, words), k: k}
+}
+
+func (b *bloomFilter) indexes(key string) []uint64 {
+	h1 := fnv.New64()
+	h1.Write([]byte(key))
+	sum1 := h1.Sum64()
+
+	h2 := fnv.New64a()
+	h2.Write([]byte(key))
+	sum2 := h2.Sum64()
+
+	numBits := uint64(len(b.bits)) * 64
+	idxs := make([]uint64, b.k)
+	for i := uint(0); i < b.k; i++ {
+		idxs[i] = (sum1 + uint64(i)*sum2) % numBits
+	}
+	return idxs
+}
+
+// Add выставляет k битов, соответствующих key, через CAS-цикл — безопасно
+// при конкурентных вызовах из нескольких горутин без общего мьютекса.
+func (b *bloomFilter) Add(key string) {
+	for _, idx := range b.indexes(key) {
+		word, bit := idx/64, idx%64
+		mask := uint64(1) << bit
+		for {
+			old := atomic.LoadUint64(&b.bits[word])
+			if old&mask != 0 {
+				break
+			}
+			if atomic.CompareAndSwapUint64(&b.bits[word], old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// MaybeContains возвращает false только если key точно не добавлялся; true
+// может быть как истинным членством, так и ложноположительным срабатыванием.
+func (b *bloomFilter) MaybeContains(key string) bool {
+	for _, idx := range b.indexes(key) {
+		word, bit := idx/64, idx%64
+		if atomic.LoadUint64(&b.bits[word])&(uint64(1)<<bit) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// nonceBloomBits выбирает размер битового массива из расчета ~10 бит на
+// ожидаемый элемент — стандартный инженерный компромисс, дающий частоту
+// ложноположительных срабатываний около 1% при k=4.
+func nonceBloomBits(expectedEntries int) uint {
+	bits := uint(expectedEntries) * 10
+	if bits < 1024 {
+		bits = 1024
+	}
+	return bits
+}
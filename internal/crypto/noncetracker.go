@@ -0,0 +1,31 @@
+package crypto
+
+import "time"
+
+// NonceTracker — интерфейс пригодного для замены хранилища предъявленных
+// nonce, используемого VerifyAndDecryptMessageWithConfig для защиты от
+// replay-атак (см. WithNonceTracker). В отличие от package-level
+// globalNonceCache (см. noncecache.go), который всегда процесс-локален,
+// разные реализации этого интерфейса можно внедрять в конкретный вызов —
+// это позволяет нескольким экземплярам сервера, разделяющим один и тот же
+// sharedSecret, согласованно отклонять replay через общее хранилище
+// (RedisNonceTracker) вместо того, чтобы каждый инстанс проверял только
+// свою собственную память.
+type NonceTracker interface {
+	// AddNonce регистрирует nonce с временем жизни ttl и возвращает ошибку,
+	// если такой nonce уже был зарегистрирован и запись еще не истекла
+	// (обнаружен replay).
+	AddNonce(nonce []byte, ttl time.Duration) error
+
+	// Count возвращает количество записей, все еще удерживаемых
+	// хранилищем (для тестирования/метрик; для некоторых backend'ов может
+	// быть приблизительным).
+	Count() int
+
+	// Reset очищает все записи.
+	Reset()
+
+	// Stop освобождает ресурсы, связанные с трекером (соединения,
+	// фоновые горутины очистки, файловые дескрипторы).
+	Stop()
+}
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/sign/dilithium/mode3"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	"client-server/tests/metrics"
+)
+
+// kyberScheme — используемая конкретная реализация Kyber из CIRCL (уровень
+// стойкости NIST-3, как и у Dilithium3 в HybridSign/HybridVerify — обе части
+// гибрида нацелены на сопоставимый уровень стойкости).
+var kyberScheme = kyber768.Scheme()
+
+// HybridKEMKeyPair хранит приватные части гибридного KEM Kyber768+X25519:
+// пост-квантовую (Kyber768, устойчивую к атаке с квантовым компьютером) и
+// классическую (X25519, подстраховка на случай непредвиденной слабости в
+// Kyber) — та же "belt-and-suspenders" конструкция, что и
+// Dilithium3Ed25519_Kyber768X25519 в бенчмарках ProtonMail.
+type HybridKEMKeyPair struct {
+	KyberPriv  kem.PrivateKey
+	X25519Priv [32]byte
+}
+
+// HybridPublicKey — публичная часть HybridKEMKeyPair, передаваемая пиру.
+type HybridPublicKey struct {
+	Kyber  []byte
+	X25519 [32]byte
+}
+
+// HybridCiphertext — данные, которые отправитель HybridEncapsulate передает
+// получателю для HybridDecapsulate: инкапсуляция Kyber768 и эфемерный
+// публичный ключ X25519 отправителя.
+type HybridCiphertext struct {
+	Kyber     []byte
+	X25519Eph [32]byte
+}
+
+// GenerateHybridKEM генерирует гибридную пару ключей Kyber768+X25519.
+func GenerateHybridKEM() (*HybridKEMKeyPair, HybridPublicKey, error) {
+	kyberPub, kyberPriv, err := kyberScheme.GenerateKeyPair()
+	if err != nil {
+		return nil, HybridPublicKey{}, err
+	}
+	x25519Priv, x25519Pub, err := GenerateX25519KeyPair()
+	if err != nil {
+		return nil, HybridPublicKey{}, err
+	}
+	kyberPubBytes, err := kyberPub.MarshalBinary()
+	if err != nil {
+		return nil, HybridPublicKey{}, err
+	}
+
+	kp := &HybridKEMKeyPair{KyberPriv: kyberPriv, X25519Priv: x25519Priv}
+	return kp, HybridPublicKey{Kyber: kyberPubBytes, X25519: x25519Pub}, nil
+}
+
+// HybridEncapsulate инкапсулирует общий секрет под публичным ключом peer:
+// инкапсулирует отдельный секрет под Kyber768, вычисляет классический X25519
+// DH с новой эфемерной парой, конкатенирует оба секрета (Kyber сначала,
+// затем X25519) и пропускает результат через HKDF-SHA256 (extract+expand) до
+// AESKeySize байт — см. deriveHybridKey.
+func HybridEncapsulate(peer HybridPublicKey) (HybridCiphertext, []byte, error) {
+	kyberPub, err := kyberScheme.UnmarshalBinaryPublicKey(peer.Kyber)
+	if err != nil {
+		return HybridCiphertext{}, nil, err
+	}
+	kyberCt, kyberSS, err := kyberScheme.Encapsulate(kyberPub)
+	if err != nil {
+		return HybridCiphertext{}, nil, err
+	}
+
+	ephPriv, ephPub, err := GenerateX25519KeyPair()
+	if err != nil {
+		return HybridCiphertext{}, nil, err
+	}
+	x25519SS, err := curve25519.X25519(ephPriv[:], peer.X25519[:])
+	if err != nil {
+		return HybridCiphertext{}, nil, err
+	}
+
+	key, err := deriveHybridKey(kyberSS, x25519SS)
+	if err != nil {
+		return HybridCiphertext{}, nil, err
+	}
+	return HybridCiphertext{Kyber: kyberCt, X25519Eph: ephPub}, key, nil
+}
+
+// HybridDecapsulate восстанавливает общий секрет, инкапсулированный
+// HybridEncapsulate, из собственной приватной пары kp.
+func HybridDecapsulate(kp *HybridKEMKeyPair, ct HybridCiphertext) ([]byte, error) {
+	kyberSS, err := kyberScheme.Decapsulate(kp.KyberPriv, ct.Kyber)
+	if err != nil {
+		return nil, err
+	}
+	x25519SS, err := curve25519.X25519(kp.X25519Priv[:], ct.X25519Eph[:])
+	if err != nil {
+		return nil, err
+	}
+	return deriveHybridKey(kyberSS, x25519SS)
+}
+
+// deriveHybridKey сводит секреты Kyber768 и X25519 в один ключ AESKeySize
+// байт через HKDF-SHA256 extract+expand с фиксированной info-строкой (без
+// соли — обе стороны должны вывести один и тот же ключ без обмена
+// дополнительными данными).
+func deriveHybridKey(kyberSS, x25519SS []byte) ([]byte, error) {
+	combined := append(append([]byte{}, kyberSS...), x25519SS...)
+	kdf := hkdf.New(sha256.New, combined, nil, []byte("hybrid-kem-kyber768-x25519"))
+	out := make([]byte, AESKeySize)
+	if _, err := kdf.Read(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HybridSignKeyPair хранит приватные и публичные ключи для гибридной
+// подписи Dilithium3+Ed25519.
+type HybridSignKeyPair struct {
+	DilithiumPriv *mode3.PrivateKey
+	DilithiumPub  *mode3.PublicKey
+	Ed25519Priv   ed25519.PrivateKey
+	Ed25519Pub    ed25519.PublicKey
+}
+
+// GenerateHybridSignKeyPair генерирует гибридную пару ключей для подписи
+// Dilithium3+Ed25519.
+func GenerateHybridSignKeyPair() (*HybridSignKeyPair, error) {
+	dilithiumPub, dilithiumPriv, err := mode3.GenerateKey(DefaultRand)
+	if err != nil {
+		return nil, err
+	}
+	ed25519Pub, ed25519Priv, err := ed25519.GenerateKey(DefaultRand)
+	if err != nil {
+		return nil, err
+	}
+	return &HybridSignKeyPair{
+		DilithiumPriv: dilithiumPriv,
+		DilithiumPub:  dilithiumPub,
+		Ed25519Priv:   ed25519Priv,
+		Ed25519Pub:    ed25519Pub,
+	}, nil
+}
+
+// HybridSign подписывает data обеими схемами и конкатенирует подписи:
+// [Dilithium3-подпись][Ed25519-подпись]. Ed25519-часть имеет фиксированную
+// длину ed25519.SignatureSize, что позволяет HybridVerify разделить их
+// обратно без отдельного кодирования длины.
+func HybridSign(kp *HybridSignKeyPair, data []byte, stats *metrics.SecurityStats) []byte {
+	start := time.Now()
+	dilithiumSig := make([]byte, mode3.SignatureSize)
+	mode3.SignTo(kp.DilithiumPriv, data, dilithiumSig)
+	ed25519Sig := ed25519.Sign(kp.Ed25519Priv, data)
+	if stats != nil {
+		stats.RecordSigningTime(time.Since(start))
+	}
+	return append(dilithiumSig, ed25519Sig...)
+}
+
+// HybridVerify проверяет гибридную подпись HybridSign: обе схемы должны
+// подтвердить подлинность, иначе подпись считается недействительной —
+// компрометация одной из схем (например, будущая атака на Dilithium или на
+// Ed25519) сама по себе не позволяет подделать подпись.
+func HybridVerify(kp *HybridSignKeyPair, data, sig []byte, stats *metrics.SecurityStats) bool {
+	if len(sig) != mode3.SignatureSize+ed25519.SignatureSize {
+		return false
+	}
+	dilithiumSig := sig[:mode3.SignatureSize]
+	ed25519Sig := sig[mode3.SignatureSize:]
+
+	start := time.Now()
+	dilithiumOK := mode3.Verify(kp.DilithiumPub, data, dilithiumSig)
+	ed25519OK := ed25519.Verify(kp.Ed25519Pub, data, ed25519Sig)
+	if stats != nil {
+		stats.RecordVerificationTime(time.Since(start))
+	}
+	return dilithiumOK && ed25519OK
+}
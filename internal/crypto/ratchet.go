@@ -0,0 +1,396 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+// Метки KDF-шагов ratchet'а — каждая выводит независимый ключ из общего
+// входа через HMAC-SHA256, как и hkdfExpand в aead.go, но здесь достаточно
+// одного раунда HMAC на метку (метки не пересекаются по смыслу).
+const (
+	ratchetLabelInitialChain  = "initial-chain"
+	ratchetLabelInitialHeader = "initial-header"
+	ratchetLabelNextHeaderKey = "next-header-key"
+	ratchetLabelRootKey       = "root-key"
+	ratchetLabelChainKey      = "chain-key"
+	ratchetLabelMessageKey    = "message-key"
+	ratchetLabelChainKeyStep  = "chain-key-step"
+	ratchetLabelMsgEncKey     = "msg-enc"
+	ratchetLabelMsgMacKey     = "msg-mac"
+)
+
+// ratchetRootUpdateLabel — префикс входа KDF-шага корневого ключа:
+// keyMaterial = SHA256(ratchetRootUpdateLabel ‖ rootKey ‖ sharedKey).
+var ratchetRootUpdateLabel = []byte("root-key-update")
+
+// maxSkippedMessageKeys — предел числа кэшируемых ключей пропущенных (из-за
+// недоставки по порядку) сообщений одной цепочки. Без потолка атакующий,
+// присылающий сообщения с заведомо большим sendCount, мог бы заставить
+// Decrypt накапливать неограниченное число ключей в памяти — тот же класс
+// DoS, что и у nonce (см. ShardedNonceCache в noncecache.go).
+const maxSkippedMessageKeys = 1000
+
+var (
+	errRatchetNoHeader         = errors.New("ratchet: сообщение не содержит RatchetHeader")
+	errRatchetHeaderDecryption = errors.New("ratchet: не удалось расшифровать заголовок сообщения")
+	errRatchetUnexpectedPub    = errors.New("ratchet: публичный ключ отправителя изменился без DH-шага")
+	errRatchetKeyUnavailable   = errors.New("ratchet: ключ сообщения недоступен (слишком старое или уже доставленное сообщение)")
+	errRatchetTooManySkipped   = errors.New("ratchet: слишком много пропущенных сообщений в цепочке")
+)
+
+// RatchetHeader несет метаданные DH-ratchet'а, которые должны быть известны
+// получателю до расшифровки полезной нагрузки: номер сообщения в текущей
+// цепочке отправки, число сообщений в предыдущей цепочке (чтобы получатель
+// знал, сколько ключей предыдущей цепочки еще может понадобиться) и свежий
+// публичный ключ отправителя, зашифрованный под текущим ключом заголовка
+// (header key), чтобы пассивный наблюдатель не видел ratchet-ключи напрямую.
+type RatchetHeader struct {
+	SendCount     uint32
+	PrevSendCount uint32
+	RatchetPub    []byte // sendRatchetPub, зашифрованный AES-256-CBC под header key
+	HeaderIV      []byte
+}
+
+// Ratchet реализует Axolotl/Signal-style Double Ratchet поверх общего
+// секрета, полученного из ECDH (см. ComputeSharedSecret): каждое исходящее
+// сообщение шифруется собственным, производным от цепочки ключом, так что
+// компрометация одного ключа сообщения не раскрывает ни более ранние, ни
+// более поздние сообщения.
+//
+// Упрощение относительно полного протокола Signal: начальное состояние
+// (rootKey и симметричные цепочки) выводится одинаково на обеих сторонах
+// напрямую из общего sharedSecret (а не через X3DH), поэтому сообщения,
+// отправленные стороной до получения первого сообщения собеседника,
+// шифруются еще "досимметричным" ключом начальной цепочки, без DH-шага —
+// DH-ratchet (и, соответственно, forward secrecy на уровне отдельных
+// сообщений) проворачивается при каждой отправке, следующей за принятым
+// сообщением собеседника. Долгосрочная аутентификация сторон
+// (ECDSA/RSA) по-прежнему выполняется один раз при установлении сессии (см.
+// CreateSecureMessage) — перешифровывать статическими ключами каждое
+// сообщение ratchet'а избыточно и противоречит самой идее ratchet'а.
+type Ratchet struct {
+	rootKey       [32]byte
+	sendChainKey  [32]byte
+	recvChainKey  [32]byte
+	sendHeaderKey [32]byte
+	recvHeaderKey [32]byte
+
+	// sendRatchetPriv/Pub — текущая DH-пара этой стороны. В Double Ratchet
+	// обе стороны используют одну и ту же текущую пару и для вычисления
+	// общего ключа при получении чужого нового публичного ключа, и (при
+	// необходимости сгенерировать новую) для следующей исходящей цепочки.
+	sendRatchetPriv *ecdsa.PrivateKey
+	sendRatchetPub  []byte
+	recvRatchetPub  []byte
+
+	sendCount     uint32
+	recvCount     uint32
+	prevSendCount uint32
+
+	// needDHStep истинно, если с последнего исходящего DH-шага было принято
+	// хотя бы одно сообщение — тогда следующий Encrypt обязан провернуть
+	// DH-ratchet перед шифрованием.
+	needDHStep bool
+
+	// skippedKeys кэширует ключи сообщений, пропущенных из-за недоставки по
+	// порядку, по ключу "<hex recvRatchetPub>:<count>".
+	skippedKeys map[string][32]byte
+
+	rnd   io.Reader
+	stats *metrics.SecurityStats
+}
+
+// NewRatchet создает Ratchet поверх общего секрета sharedSecret (например,
+// результата ComputeSharedSecret), используя DefaultRand как источник
+// энтропии для будущих DH-ключей и IV.
+func NewRatchet(sharedSecret []byte) *Ratchet {
+	return NewRatchetWithRand(sharedSecret, DefaultRand, nil)
+}
+
+// NewRatchetWithRand создает Ratchet, читая энтропию из переданного rnd
+// вместо DefaultRand — позволяет внедрить детерминированный источник (см.
+// NewDeterministicReader) для воспроизводимых тестов, и передает stats для
+// учета времени шифрования/расшифровки в метриках.
+func NewRatchetWithRand(sharedSecret []byte, rnd io.Reader, stats *metrics.SecurityStats) *Ratchet {
+	root := sha256.Sum256(sharedSecret)
+	initChain := to32(ratchetHMAC(root[:], ratchetLabelInitialChain))
+	initHeader := to32(ratchetHMAC(root[:], ratchetLabelInitialHeader))
+
+	priv, pub := GenerateECDHKeysWithRand(rnd)
+
+	return &Ratchet{
+		rootKey:         root,
+		sendChainKey:    initChain,
+		recvChainKey:    initChain,
+		sendHeaderKey:   initHeader,
+		recvHeaderKey:   initHeader,
+		sendRatchetPriv: priv,
+		sendRatchetPub:  pub,
+		skippedKeys:     make(map[string][32]byte),
+		rnd:             rnd,
+		stats:           stats,
+	}
+}
+
+// Encrypt шифрует plaintext под очередным ключом цепочки отправки,
+// проворачивая DH-ratchet перед этим, если с прошлой отправки было получено
+// сообщение от собеседника.
+func (r *Ratchet) Encrypt(plaintext []byte) (Message, error) {
+	if r.needDHStep && r.recvRatchetPub != nil {
+		if err := r.dhStepSend(); err != nil {
+			return Message{}, err
+		}
+	}
+
+	messageKey := to32(ratchetHMAC(r.sendChainKey[:], ratchetLabelMessageKey))
+	r.sendChainKey = to32(ratchetHMAC(r.sendChainKey[:], ratchetLabelChainKeyStep))
+
+	encKey, macKey, err := deriveRatchetMessageSubkeys(messageKey)
+	if err != nil {
+		return Message{}, err
+	}
+
+	iv, err := NewIVWithRand(r.rnd)
+	if err != nil {
+		return Message{}, err
+	}
+	ciphertext := AESEncrypt(encKey, iv, plaintext, r.stats)
+	hmacValue := GenerateHMAC(macKey, ciphertext)
+
+	headerIV, err := NewIVWithRand(r.rnd)
+	if err != nil {
+		return Message{}, err
+	}
+	ratchetPubEnc := AESEncrypt(r.sendHeaderKey[:], headerIV, r.sendRatchetPub, r.stats)
+
+	header := &RatchetHeader{
+		SendCount:     r.sendCount,
+		PrevSendCount: r.prevSendCount,
+		RatchetPub:    ratchetPubEnc,
+		HeaderIV:      headerIV,
+	}
+	r.sendCount++
+
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(r.rnd, nonce); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Timestamp:     time.Now().Unix(),
+		Nonce:         nonce,
+		IV:            iv,
+		Cipher:        ciphertext,
+		HMAC:          hmacValue,
+		CipherSuite:   SuiteAESCBCHMAC,
+		RatchetHeader: header,
+	}, nil
+}
+
+// Decrypt проверяет timestamp и nonce сообщения (переиспользуя тот же
+// глобальный кэш nonce и окно MaxTimeDifference, что и
+// VerifyAndDecryptMessage), при необходимости проворачивает DH-ratchet на
+// приемной стороне и расшифровывает полезную нагрузку ключом, выведенным из
+// цепочки получения — восстанавливая пропущенные ключи, если сообщения
+// доставлены не по порядку.
+func (r *Ratchet) Decrypt(msg Message) ([]byte, error) {
+	if msg.RatchetHeader == nil {
+		return nil, errRatchetNoHeader
+	}
+
+	now := time.Now().Unix()
+	if now-msg.Timestamp > MaxTimeDifference || now < msg.Timestamp-MaxTimeDifference {
+		return nil, errors.New("временная метка вне допустимого диапазона")
+	}
+	if err := globalNonceCache.AddNonce(msg.Nonce); err != nil {
+		return nil, err
+	}
+
+	peerPub, isNewChain, err := r.decryptHeaderPub(msg.RatchetHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	if isNewChain {
+		if err := r.dhStepRecv(peerPub); err != nil {
+			return nil, err
+		}
+	} else if r.recvRatchetPub == nil {
+		r.recvRatchetPub = peerPub
+	} else if !bytes.Equal(peerPub, r.recvRatchetPub) {
+		return nil, errRatchetUnexpectedPub
+	}
+
+	messageKey, err := r.messageKeyForCount(msg.RatchetHeader.SendCount)
+	if err != nil {
+		return nil, err
+	}
+
+	encKey, macKey, err := deriveRatchetMessageSubkeys(messageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !VerifyHMAC(macKey, msg.Cipher, msg.HMAC) {
+		return nil, errors.New("проверка HMAC не удалась")
+	}
+	plaintext, err := AESDecrypt(encKey, msg.IV, msg.Cipher, r.stats)
+	if err != nil {
+		return nil, err
+	}
+	r.needDHStep = true
+	return plaintext, nil
+}
+
+// dhStepSend проворачивает DH-ratchet отправки: генерирует свежую DH-пару,
+// вычисляет общий ключ с текущим известным публичным ключом собеседника и
+// обновляет rootKey/sendChainKey/sendHeaderKey.
+func (r *Ratchet) dhStepSend() error {
+	newPriv, newPub := GenerateECDHKeysWithRand(r.rnd)
+	nextHeaderKey := to32(ratchetHMAC(r.rootKey[:], ratchetLabelNextHeaderKey))
+
+	sharedKey := ComputeSharedSecret(newPriv, r.recvRatchetPub)
+	keyMaterial := sha256.Sum256(append(append(append([]byte{}, ratchetRootUpdateLabel...), r.rootKey[:]...), sharedKey...))
+
+	r.rootKey = to32(ratchetHMAC(keyMaterial[:], ratchetLabelRootKey))
+	r.sendChainKey = to32(ratchetHMAC(keyMaterial[:], ratchetLabelChainKey))
+	r.sendHeaderKey = nextHeaderKey
+	r.sendRatchetPriv = newPriv
+	r.sendRatchetPub = newPub
+	r.prevSendCount = r.sendCount
+	r.sendCount = 0
+	r.needDHStep = false
+	return nil
+}
+
+// dhStepRecv — зеркало dhStepSend на приемной стороне: использует
+// собственную текущую DH-пару (ту же, что применяется для отправки) и
+// свежий публичный ключ собеседника peerPub, полученный из заголовка.
+func (r *Ratchet) dhStepRecv(peerPub []byte) error {
+	nextHeaderKey := to32(ratchetHMAC(r.rootKey[:], ratchetLabelNextHeaderKey))
+
+	sharedKey := ComputeSharedSecret(r.sendRatchetPriv, peerPub)
+	keyMaterial := sha256.Sum256(append(append(append([]byte{}, ratchetRootUpdateLabel...), r.rootKey[:]...), sharedKey...))
+
+	r.rootKey = to32(ratchetHMAC(keyMaterial[:], ratchetLabelRootKey))
+	r.recvChainKey = to32(ratchetHMAC(keyMaterial[:], ratchetLabelChainKey))
+	r.recvHeaderKey = nextHeaderKey
+	r.recvRatchetPub = peerPub
+	r.recvCount = 0
+	r.skippedKeys = make(map[string][32]byte)
+	r.needDHStep = true
+	return nil
+}
+
+// decryptHeaderPub пробует расшифровать RatchetHeader.RatchetPub сначала
+// текущим ключом заголовка цепочки получения (продолжение уже известной
+// цепочки), а при неудаче — кандидатом на следующий ключ заголовка,
+// выводимым из текущего rootKey (первое сообщение новой цепочки, до того
+// как этот получатель сам провернул DH-ratchet). Успешная расшифровка во
+// втором случае означает, что нужно провернуть DH-ratchet на приемной
+// стороне (см. dhStepRecv).
+func (r *Ratchet) decryptHeaderPub(h *RatchetHeader) (peerPub []byte, isNewChain bool, err error) {
+	if pub, decErr := AESDecrypt(r.recvHeaderKey[:], h.HeaderIV, h.RatchetPub, r.stats); decErr == nil && looksLikeECDSAPub(pub) {
+		return pub, false, nil
+	}
+
+	candidate := to32(ratchetHMAC(r.rootKey[:], ratchetLabelNextHeaderKey))
+	if pub, decErr := AESDecrypt(candidate[:], h.HeaderIV, h.RatchetPub, r.stats); decErr == nil && looksLikeECDSAPub(pub) {
+		return pub, true, nil
+	}
+
+	return nil, false, errRatchetHeaderDecryption
+}
+
+// messageKeyForCount возвращает ключ сообщения с номером count в текущей
+// цепочке получения, проворачивая цепочку вперед и кэшируя ключи
+// промежуточных (пропущенных) сообщений, либо возвращая уже закэшированный
+// ключ, если сообщение было доставлено не по порядку.
+func (r *Ratchet) messageKeyForCount(count uint32) ([32]byte, error) {
+	chainID := hex.EncodeToString(r.recvRatchetPub)
+
+	if count < r.recvCount {
+		key, ok := r.skippedKeys[skippedKeyID(chainID, count)]
+		if !ok {
+			return [32]byte{}, errRatchetKeyUnavailable
+		}
+		delete(r.skippedKeys, skippedKeyID(chainID, count))
+		return key, nil
+	}
+
+	for r.recvCount < count {
+		if len(r.skippedKeys) >= maxSkippedMessageKeys {
+			return [32]byte{}, errRatchetTooManySkipped
+		}
+		skipped := to32(ratchetHMAC(r.recvChainKey[:], ratchetLabelMessageKey))
+		r.skippedKeys[skippedKeyID(chainID, r.recvCount)] = skipped
+		r.recvChainKey = to32(ratchetHMAC(r.recvChainKey[:], ratchetLabelChainKeyStep))
+		r.recvCount++
+	}
+
+	messageKey := to32(ratchetHMAC(r.recvChainKey[:], ratchetLabelMessageKey))
+	r.recvChainKey = to32(ratchetHMAC(r.recvChainKey[:], ratchetLabelChainKeyStep))
+	r.recvCount++
+	return messageKey, nil
+}
+
+func skippedKeyID(chainID string, count uint32) string {
+	return chainID + ":" + hex.EncodeToString([]byte{byte(count >> 24), byte(count >> 16), byte(count >> 8), byte(count)})
+}
+
+// deriveRatchetMessageSubkeys выводит из ключа сообщения отдельные ключи
+// шифрования и HMAC-аутентификации через hkdfExpand — так же, как
+// DeriveDEKSubkeys выводит подключи из DEK в envelope.go, чтобы messageKey
+// никогда не использовался напрямую ни как ключ AES, ни как ключ HMAC.
+func deriveRatchetMessageSubkeys(messageKey [32]byte) (encKey, macKey []byte, err error) {
+	encKey, err = hkdfExpand(messageKey[:], ratchetLabelMsgEncKey, AESKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, err = hkdfExpand(messageKey[:], ratchetLabelMsgMacKey, HMACKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encKey, macKey, nil
+}
+
+// ratchetHMAC — HMAC-SHA256(key, []byte(label)), базовый примитив всех
+// KDF-шагов ratchet'а.
+func ratchetHMAC(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// to32 копирует первые 32 байта b в массив фиксированного размера.
+func to32(b []byte) [32]byte {
+	var out [32]byte
+	copy(out[:], b)
+	return out
+}
+
+// looksLikeECDSAPub — грубая проверка целостности расшифрованного
+// RatchetHeader.RatchetPub: заголовок не несет собственного MAC (в отличие
+// от полезной нагрузки Message), поэтому, прежде чем доверять результату
+// пробной AES-расшифровки одним из двух кандидатов ключа заголовка, мы
+// убеждаемся, что это вообще валидный DER-encoded публичный ключ ECDSA —
+// так мы отличаем успешную расшифровку от случайного успеха PKCS7-паддинга
+// на мусорных данных.
+func looksLikeECDSAPub(pub []byte) bool {
+	pubIface, err := x509.ParsePKIXPublicKey(pub)
+	if err != nil {
+		return false
+	}
+	_, ok := pubIface.(*ecdsa.PublicKey)
+	return ok
+}
@@ -0,0 +1,184 @@
+package crypto
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+// rateLimiterShardCount — количество шардов RateLimiter. clientID
+// хешируется в один из них, так что горячий путь Allow берет мьютекс только
+// своего шарда вместо единственного общего, как было в прежнем
+// RateLimitCheck.
+const rateLimiterShardCount = 16
+
+// rateLimiterSweepInterval — период фоновой очистки простаивающих бакетов
+// (см. RateLimiter.sweepLoop).
+const rateLimiterSweepInterval = 30 * time.Second
+
+// rateLimiterIdleTimeout — бакет считается устаревшим и вычищается, если с
+// последнего обращения к нему прошло больше этого времени.
+const rateLimiterIdleTimeout = 5 * time.Minute
+
+// rateLimiterDefaultCapacity/rateLimiterDefaultRefillPerSec — параметры
+// defaultRateLimiter, используемого RateLimitCheck. refillPerSec выбран
+// равным 1/minOpInterval прежней реализации (10 мс между операциями), а
+// capacity допускает всплеск до 10 операций подряд, которого прежняя
+// реализация не допускала вовсе.
+const (
+	rateLimiterDefaultCapacity     = 10
+	rateLimiterDefaultRefillPerSec = 100
+)
+
+// defaultRateLimiter — общий для всего процесса RateLimiter, на который
+// опирается RateLimitCheck.
+var defaultRateLimiter = NewRateLimiter(rateLimiterDefaultCapacity, rateLimiterDefaultRefillPerSec)
+
+// RateLimitError возвращается RateLimiter.Allow, когда у clientID не
+// осталось токенов. RetryAfter — через сколько у бакета появится
+// достаточно токенов для следующего Allow, при текущей скорости восполнения.
+type RateLimitError struct {
+	ClientID   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit: клиент %q исчерпал лимит, повторите через %v", e.ClientID, e.RetryAfter)
+}
+
+// bucket — токен-бакет одного клиента: tokens восполняется лениво при
+// каждом обращении Allow пропорционально прошедшему времени (elapsed*
+// refillPerSec), не превышая capacity.
+type bucket struct {
+	tokens       float64
+	lastRefill   time.Time
+	capacity     float64
+	refillPerSec float64
+}
+
+// rateLimiterShard — один шард RateLimiter: собственный мьютекс и карта
+// бакетов, не пересекающиеся с другими шардами.
+type rateLimiterShard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// RateLimiter — шардированный токен-бакет лимитер скорости операций,
+// заменяющий прежний RateLimitCheck (единственный sync.Mutex вокруг
+// map[string]time.Time, фиксированный минимальный интервал между
+// операциями без поддержки всплесков и полный проход по карте на каждый
+// вызов). Устаревшие бакеты вычищает отдельная фоновая горутина
+// (sweepLoop), запускаемая NewRateLimiter, — Allow никогда не обходит всю
+// карту целиком.
+type RateLimiter struct {
+	shards       [rateLimiterShardCount]*rateLimiterShard
+	capacity     float64
+	refillPerSec float64
+	idleTimeout  time.Duration
+	stopCh       chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewRateLimiter создает RateLimiter с заданной емкостью бакета (capacity,
+// максимальный размер всплеска) и скоростью восполнения токенов в секунду
+// (refillPerSec) и запускает фоновую горутину очистки простаивающих
+// бакетов. Вызывающая сторона обязана вызвать Close, когда лимитер больше
+// не нужен, иначе горутина очистки останется работать до конца процесса.
+func NewRateLimiter(capacity, refillPerSec float64) *RateLimiter {
+	rl := &RateLimiter{
+		capacity:     capacity,
+		refillPerSec: refillPerSec,
+		idleTimeout:  rateLimiterIdleTimeout,
+		stopCh:       make(chan struct{}),
+	}
+	for i := range rl.shards {
+		rl.shards[i] = &rateLimiterShard{buckets: make(map[string]*bucket)}
+	}
+	go rl.sweepLoop()
+	return rl
+}
+
+// Close останавливает фоновую горутину очистки. Повторные вызовы безопасны.
+func (rl *RateLimiter) Close() {
+	rl.closeOnce.Do(func() { close(rl.stopCh) })
+}
+
+func (rl *RateLimiter) sweepLoop() {
+	ticker := time.NewTicker(rateLimiterSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rl.stopCh:
+			return
+		case <-ticker.C:
+			rl.sweep()
+		}
+	}
+}
+
+// sweep проходит по всем шардам и удаляет бакеты, простаивающие дольше
+// idleTimeout. Единственное место в RateLimiter, обходящее карту целиком —
+// выполняется редко и в фоне, а не на горячем пути Allow.
+func (rl *RateLimiter) sweep() {
+	cutoff := time.Now().Add(-rl.idleTimeout)
+	for _, shard := range rl.shards {
+		shard.mu.Lock()
+		for clientID, b := range shard.buckets {
+			if b.lastRefill.Before(cutoff) {
+				delete(shard.buckets, clientID)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// shardFor хеширует clientID в один из rl.shards через FNV-1a — не
+// криптографический хеш, но от него и не требуется стойкость, только
+// равномерное распределение клиентов по шардам.
+func (rl *RateLimiter) shardFor(clientID string) *rateLimiterShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(clientID))
+	return rl.shards[h.Sum32()%rateLimiterShardCount]
+}
+
+// Allow лениво восполняет бакет clientID по прошедшему с прошлого
+// обращения времени (tokens += elapsed*refillPerSec, не выше capacity),
+// затем пытается потребить один токен. Возвращает *RateLimitError, если
+// токенов не осталось. Если stats не nil, решение учитывается в нем через
+// RecordRateLimitDecision.
+func (rl *RateLimiter) Allow(clientID string, stats *metrics.SecurityStats) error {
+	shard := rl.shardFor(clientID)
+
+	shard.mu.Lock()
+	b, ok := shard.buckets[clientID]
+	if !ok {
+		b = &bucket{tokens: rl.capacity, lastRefill: time.Now(), capacity: rl.capacity, refillPerSec: rl.refillPerSec}
+		shard.buckets[clientID] = b
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens += elapsed * b.refillPerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastRefill = now
+
+	var err error
+	if b.tokens < 1 {
+		missing := 1 - b.tokens
+		retryAfter := time.Duration(missing / b.refillPerSec * float64(time.Second))
+		err = &RateLimitError{ClientID: clientID, RetryAfter: retryAfter}
+	} else {
+		b.tokens--
+	}
+	shard.mu.Unlock()
+
+	if stats != nil {
+		stats.RecordRateLimitDecision(clientID, err == nil)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisNonceTracker — реализация NonceTracker поверх Redis, пригодная для
+// нескольких экземпляров сервера, разделяющих один sharedSecret: атомарная
+// проверка-и-вставка обеспечивается командой SET key NX EX ttl — инстанс,
+// получивший тот же nonce позже первого, пока ключ первого еще не истек по
+// ttl, получит NX=false и AddNonce вернет ошибку replay независимо от того,
+// какой именно инстанс принял nonce первым.
+type RedisNonceTracker struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisNonceTracker оборачивает уже сконфигурированный client, добавляя
+// keyPrefix к каждому ключу nonce — позволяет нескольким трекерам
+// использовать один Redis для независимых пространств имен (например, по
+// sharedSecret-сессии).
+func NewRedisNonceTracker(client *redis.Client, keyPrefix string) *RedisNonceTracker {
+	return &RedisNonceTracker{client: client, keyPrefix: keyPrefix}
+}
+
+func (rt *RedisNonceTracker) AddNonce(nonce []byte, ttl time.Duration) error {
+	key := rt.keyPrefix + string(nonce)
+	ok, err := rt.client.SetNX(context.Background(), key, "1", ttl).Result()
+	if err != nil {
+		return fmt.Errorf("RedisNonceTracker: %w", err)
+	}
+	if !ok {
+		return errors.New("nonce уже использован (replay attack обнаружен)")
+	}
+	return nil
+}
+
+// Count возвращает количество живых ключей с keyPrefix. Реализован через
+// KEYS (O(n) по размеру базы) — приемлемо для тестов/метрик, но не для
+// горячего пути в проде с большой базой (там лучше завести отдельный
+// счетчик рядом, например через INCR).
+func (rt *RedisNonceTracker) Count() int {
+	keys, err := rt.client.Keys(context.Background(), rt.keyPrefix+"*").Result()
+	if err != nil {
+		return -1
+	}
+	return len(keys)
+}
+
+// Reset удаляет все ключи с keyPrefix.
+func (rt *RedisNonceTracker) Reset() {
+	keys, err := rt.client.Keys(context.Background(), rt.keyPrefix+"*").Result()
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	rt.client.Del(context.Background(), keys...)
+}
+
+// Stop закрывает соединение с Redis.
+func (rt *RedisNonceTracker) Stop() {
+	rt.client.Close()
+}
@@ -0,0 +1,24 @@
+package crypto
+
+// RekeyLabel — метка HKDF-Expand, используемая при продвижении секрета сессии,
+// по аналогии со схемой обновления ключей TLS 1.3 (key update).
+const RekeyLabel = "kex update"
+
+// DefaultRekeyByteLimit — порог объема данных, после которого отправитель
+// должен инициировать ротацию ключей сессии.
+const (
+	DefaultRekeyByteLimit = 1 << 30 // 1 GiB
+)
+
+// AdvanceSecret вычисляет следующий секрет эпохи по схеме
+// next_secret = HKDF-Expand(current_secret, "kex update", 32), давая каждой
+// новой эпохе независимый ключевой материал без повторного ECDH.
+func AdvanceSecret(currentSecret []byte) ([]byte, error) {
+	return hkdfExpand(currentSecret, RekeyLabel, 32)
+}
+
+// KeyUpdate — управляющий кадр, которым отправитель сигнализирует о переходе
+// к следующей эпохе ключей сессии.
+type KeyUpdate struct {
+	NextEpoch uint64
+}
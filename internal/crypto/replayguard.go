@@ -0,0 +1,188 @@
+package crypto
+
+import (
+	"container/list"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// replayGuardShardCount — количество шардов ReplayGuard, по аналогии с
+// rateLimiterShardCount в ratelimiter.go: Check берет мьютекс только своего
+// шарда вместо единственного общего.
+const replayGuardShardCount = 16
+
+// replayGuardWindowSize — ширина скользящего окна анти-replay в битах,
+// совпадает с разрядностью битовой карты replayWindow.bitmap (uint64).
+// Сообщение с номером последовательности high-replayGuardWindowSize и ниже
+// считается устаревшим и отклоняется безусловно — так же, как в anti-replay
+// окне IPsec (RFC 6479).
+const replayGuardWindowSize = 64
+
+// replayGuardDefaultMaxClientsPerShard — сколько окон клиентов хранит один
+// шард прежде, чем ReplayGuard начнет вытеснять наименее недавно
+// использованные (см. replayGuardShard.evictLocked) — граница потребления
+// памяти независимо от того, сколько разных clientID когда-либо обращалось.
+const replayGuardDefaultMaxClientsPerShard = 4096
+
+// defaultReplayGuard — общий для всего процесса ReplayGuard.
+var defaultReplayGuard = NewReplayGuard(replayGuardDefaultMaxClientsPerShard)
+
+// ErrReplayDetected возвращается ReplayGuard.Check, если номер
+// последовательности уже был принят ранее либо находится ниже скользящего
+// окна (устарел).
+var ErrReplayDetected = errors.New("обнаружен повтор сообщения (replay): номер последовательности уже принят или устарел")
+
+// replayWindow — состояние анти-replay окна одного клиента: high — largest
+// принятый номер последовательности, bitmap — какие из последних
+// replayGuardWindowSize номеров (high, high-1, ..., high-63) уже приняты
+// (бит i соответствует номеру high-i).
+type replayWindow struct {
+	high    uint64
+	bitmap  uint64
+	hasHigh bool
+}
+
+// accept проверяет seq по текущему окну и, если он допустим, отмечает его
+// принятым и при необходимости сдвигает окно вперед.
+func (rw *replayWindow) accept(seq uint64) error {
+	if !rw.hasHigh {
+		rw.high = seq
+		rw.bitmap = 1
+		rw.hasHigh = true
+		return nil
+	}
+
+	switch {
+	case seq > rw.high:
+		shift := seq - rw.high
+		if shift >= replayGuardWindowSize {
+			rw.bitmap = 0
+		} else {
+			rw.bitmap <<= shift
+		}
+		rw.bitmap |= 1
+		rw.high = seq
+		return nil
+	case rw.high-seq >= replayGuardWindowSize:
+		return ErrReplayDetected
+	default:
+		bit := uint64(1) << (rw.high - seq)
+		if rw.bitmap&bit != 0 {
+			return ErrReplayDetected
+		}
+		rw.bitmap |= bit
+		return nil
+	}
+}
+
+// replayClientEntry — элемент LRU-списка шарда: clientID нужен в значении,
+// чтобы evictLocked мог удалить соответствующую запись из карты шарда по
+// обратной стороне списка, не храня отдельный индекс "элемент -> clientID".
+type replayClientEntry struct {
+	clientID string
+	window   replayWindow
+}
+
+// replayGuardShard — один шард ReplayGuard: собственный мьютекс, карта
+// clientID -> элемент LRU-списка и сам список (front = недавно
+// использованные, back — кандидаты на вытеснение).
+type replayGuardShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	maxSize int
+}
+
+func newReplayGuardShard(maxSize int) *replayGuardShard {
+	return &replayGuardShard{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+	}
+}
+
+func (s *replayGuardShard) check(clientID string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[clientID]
+	if !ok {
+		elem = s.order.PushFront(&replayClientEntry{clientID: clientID})
+		s.entries[clientID] = elem
+		s.evictLocked()
+	}
+
+	entry := elem.Value.(*replayClientEntry)
+	if err := entry.window.accept(seq); err != nil {
+		return err
+	}
+	s.order.MoveToFront(elem)
+	return nil
+}
+
+// evictLocked вытесняет наименее недавно использованные окна клиентов, пока
+// число записей в шарде не уложится в maxSize. Вызывается под s.mu.
+func (s *replayGuardShard) evictLocked() {
+	for len(s.entries) > s.maxSize {
+		back := s.order.Back()
+		if back == nil {
+			return
+		}
+		entry := back.Value.(*replayClientEntry)
+		delete(s.entries, entry.clientID)
+		s.order.Remove(back)
+	}
+}
+
+func (s *replayGuardShard) reset(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[clientID]; ok {
+		delete(s.entries, clientID)
+		s.order.Remove(elem)
+	}
+}
+
+// ReplayGuard — защита от повторного воспроизведения сообщений (replay),
+// которой не было ни у AESEncrypt/AESDecrypt, ни у AEADEncrypt/AEADDecrypt:
+// перехваченную пару шифротекст+nonce можно было отправить повторно сколько
+// угодно раз. ReplayGuard держит по скользящему окну последних
+// replayGuardWindowSize номеров последовательности на каждый clientID (см.
+// replayWindow, тот же подход, что и anti-replay окно IPsec) в шардированной
+// карте с LRU-вытеснением, ограничивающим потребление памяти независимо от
+// количества различных clientID.
+type ReplayGuard struct {
+	shards [replayGuardShardCount]*replayGuardShard
+}
+
+// NewReplayGuard создает ReplayGuard, каждый шард которого хранит не более
+// maxClientsPerShard окон клиентов прежде, чем начнет вытеснять наименее
+// недавно использованные.
+func NewReplayGuard(maxClientsPerShard int) *ReplayGuard {
+	rg := &ReplayGuard{}
+	for i := range rg.shards {
+		rg.shards[i] = newReplayGuardShard(maxClientsPerShard)
+	}
+	return rg
+}
+
+func (rg *ReplayGuard) shardFor(clientID string) *replayGuardShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(clientID))
+	return rg.shards[h.Sum32()%replayGuardShardCount]
+}
+
+// Check принимает seq для clientID, если он больше текущего high либо
+// находится в пределах скользящего окна и еще не был отмечен принятым, и в
+// этом случае отмечает его принятым. Иначе возвращает ErrReplayDetected.
+func (rg *ReplayGuard) Check(clientID string, seq uint64) error {
+	return rg.shardFor(clientID).check(clientID, seq)
+}
+
+// Reset сбрасывает окно анти-replay для clientID — например, после ротации
+// ключей сессии (rekey), когда согласованные стороны обнуляют счетчики
+// последовательности заново.
+func (rg *ReplayGuard) Reset(clientID string) {
+	rg.shardFor(clientID).reset(clientID)
+}
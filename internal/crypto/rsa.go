@@ -2,10 +2,11 @@ package crypto
 
 import (
 	"crypto"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"errors"
+	"io"
 	"log"
 	"time"
 
@@ -14,11 +15,85 @@ import (
 
 const RSAKeySize = 2048
 
+var errRSADecryption = errors.New("RSA: не удалось расшифровать данные")
+
+// RSAEncryptOAEP шифрует plaintext открытым ключом RSA (PKIX-кодирование) по
+// схеме OAEP-SHA256 — рекомендуемая схема RSA-шифрования для нового кода
+// (в отличие от RSAEncryptPKCS1v15, уязвимой к атаке Блейхенбахера).
+func RSAEncryptOAEP(pubBytes, plaintext []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	pub, err := parseRSAPublicKey(pubBytes)
+	if err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), DefaultRand, pub, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+	stats.RecordEncryptionTime(time.Since(startTime))
+	return ciphertext, nil
+}
+
+// RSADecryptOAEP расшифровывает ciphertext, созданный RSAEncryptOAEP. Как и
+// стандартная библиотека, при любой ошибке (поврежденный padding, неверная
+// длина, ошибка хэша) возвращает один и тот же errRSADecryption — это
+// предотвращает padding-oracle атаки, основанные на различимости ошибок.
+func RSADecryptOAEP(priv *rsa.PrivateKey, ciphertext []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	startTime := time.Now()
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), DefaultRand, priv, ciphertext, nil)
+	stats.RecordDecryptionTime(time.Since(startTime))
+	if err != nil {
+		return nil, errRSADecryption
+	}
+	return plaintext, nil
+}
+
+// RSAEncryptPKCS1v15 шифрует plaintext по устаревшей схеме PKCS#1 v1.5.
+// Присутствует только для сравнения в TestPaddingOracleAttack — схема
+// уязвима к атаке Блейхенбахера на адаптивный padding-oracle и не должна
+// использоваться в новом коде (см. RSAEncryptOAEP).
+func RSAEncryptPKCS1v15(pubBytes, plaintext []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	pub, err := parseRSAPublicKey(pubBytes)
+	if err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	ciphertext, err := rsa.EncryptPKCS1v15(DefaultRand, pub, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	stats.RecordEncryptionTime(time.Since(startTime))
+	return ciphertext, nil
+}
+
+// RSADecryptPKCS1v15 расшифровывает ciphertext, созданный RSAEncryptPKCS1v15.
+func RSADecryptPKCS1v15(priv *rsa.PrivateKey, ciphertext []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	startTime := time.Now()
+	plaintext, err := rsa.DecryptPKCS1v15(DefaultRand, priv, ciphertext)
+	stats.RecordDecryptionTime(time.Since(startTime))
+	if err != nil {
+		return nil, errRSADecryption
+	}
+	return plaintext, nil
+}
+
+func parseRSAPublicKey(pubBytes []byte) (*rsa.PublicKey, error) {
+	pubIface, err := x509.ParsePKIXPublicKey(pubBytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := pubIface.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("не является открытым ключом RSA")
+	}
+	return pub, nil
+}
+
 // SignRSA создает подпись RSA для данных
 func SignRSA(priv *rsa.PrivateKey, data []byte, stats *metrics.SecurityStats) []byte {
 	startTime := time.Now()
 	h := sha256.Sum256(data)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, h[:])
+	signature, err := rsa.SignPKCS1v15(DefaultRand, priv, crypto.SHA256, h[:])
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -45,9 +120,84 @@ func VerifyRSA(pubBytes, data, sig []byte, stats *metrics.SecurityStats) bool {
 	return err == nil
 }
 
-// GenerateRSAKeys генерирует пару ключей RSA
+// SignRSAPSS создает подпись RSA-PSS для данных с солью длины хэша
+// (rsa.PSSSaltLengthEqualsHash). В отличие от SignRSA (детерминированная
+// схема PKCS1v15), PSS рандомизирует подпись, что является рекомендуемой
+// схемой для новых протоколов.
+func SignRSAPSS(priv *rsa.PrivateKey, data []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return SignRSAPSSWithSaltLength(priv, data, rsa.PSSSaltLengthEqualsHash, stats)
+}
+
+// VerifyRSAPSS проверяет подпись RSA-PSS с солью длины хэша.
+func VerifyRSAPSS(pubBytes, data, sig []byte, stats *metrics.SecurityStats) bool {
+	return VerifyRSAPSSWithSaltLength(pubBytes, data, sig, rsa.PSSSaltLengthEqualsHash, stats)
+}
+
+// SignRSAPSSWithSaltLength создает подпись RSA-PSS с явно заданной длиной
+// соли. saltLen принимает значение rsa.PSSSaltLengthEqualsHash (соль равна
+// длине хэша) или rsa.PSSSaltLengthAuto (максимально возможная соль: размер
+// ключа минус длина хэша минус 2).
+func SignRSAPSSWithSaltLength(priv *rsa.PrivateKey, data []byte, saltLen int, stats *metrics.SecurityStats) ([]byte, error) {
+	startTime := time.Now()
+	h := sha256.Sum256(data)
+	opts := &rsa.PSSOptions{SaltLength: saltLen, Hash: crypto.SHA256}
+	signature, err := rsa.SignPSS(DefaultRand, priv, crypto.SHA256, h[:], opts)
+	if err != nil {
+		return nil, err
+	}
+	stats.RecordSigningTime(time.Since(startTime))
+	return signature, nil
+}
+
+// VerifyRSAPSSWithSaltLength проверяет подпись RSA-PSS с явно заданной длиной
+// соли (см. SignRSAPSSWithSaltLength). saltLen передается в rsa.VerifyPSS как
+// есть: rsa.PSSSaltLengthAuto принимает подпись с солью любой длины, а
+// rsa.PSSSaltLengthEqualsHash или конкретное число требуют соль именно
+// такой длины.
+func VerifyRSAPSSWithSaltLength(pubBytes, data, sig []byte, saltLen int, stats *metrics.SecurityStats) bool {
+	startTime := time.Now()
+	pubIface, err := x509.ParsePKIXPublicKey(pubBytes)
+	if err != nil {
+		log.Println("Ошибка при разборе открытого ключа RSA:", err)
+		return false
+	}
+	pub, ok := pubIface.(*rsa.PublicKey)
+	if !ok {
+		log.Println("Не является открытым ключом RSA")
+		return false
+	}
+	h := sha256.Sum256(data)
+	opts := &rsa.PSSOptions{SaltLength: saltLen, Hash: crypto.SHA256}
+	err = rsa.VerifyPSS(pub, crypto.SHA256, h[:], sig, opts)
+	stats.RecordVerificationTime(time.Since(startTime))
+	return err == nil
+}
+
+// GenerateRSAKeys генерирует пару ключей RSA, используя DefaultRand в качестве
+// источника энтропии.
 func GenerateRSAKeys() (*rsa.PrivateKey, []byte) {
-	priv, err := rsa.GenerateKey(rand.Reader, RSAKeySize)
+	return GenerateRSAKeysWithRand(DefaultRand)
+}
+
+// GenerateRSAKeysWithRand генерирует пару ключей RSA, читая энтропию из
+// переданного rnd вместо DefaultRand — позволяет внедрить детерминированный
+// источник (см. NewDeterministicReader) для воспроизводимых тестов.
+func GenerateRSAKeysWithRand(rnd io.Reader) (*rsa.PrivateKey, []byte) {
+	return GenerateRSAKeysWithSizeAndRand(RSAKeySize, rnd)
+}
+
+// GenerateRSAKeysWithSize генерирует пару ключей RSA заданного размера
+// (например, 1024/2048/3072/4096 бит), используя DefaultRand в качестве
+// источника энтропии. Вызывается testDifferentKeySizes и GenerateKeyPair
+// (матрица AlgorithmProfiles); GenerateRSAKeys берет RSAKeySize (2048).
+func GenerateRSAKeysWithSize(bits int) (*rsa.PrivateKey, []byte) {
+	return GenerateRSAKeysWithSizeAndRand(bits, DefaultRand)
+}
+
+// GenerateRSAKeysWithSizeAndRand генерирует пару ключей RSA заданного
+// размера, читая энтропию из переданного rnd вместо DefaultRand.
+func GenerateRSAKeysWithSizeAndRand(bits int, rnd io.Reader) (*rsa.PrivateKey, []byte) {
+	priv, err := rsa.GenerateKey(rnd, bits)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -2,18 +2,31 @@ package crypto
 
 import (
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"sync"
 	"time"
 
 	"client-server/tests/metrics"
 )
 
+// Схемы подписи RSA, согласуемые сторонами во время обмена ключами.
+// PKCS1v15 сохранен как legacy-значение по умолчанию для обратной совместимости
+// с уже существующими вызовами CreateSecureMessage; PSS — рекомендуемая схема
+// для новых рукопожатий (см. SignRSAPSS/VerifyRSAPSS). PSSMaxSalt использует
+// максимально возможную длину соли (rsa.PSSSaltLengthAuto) вместо длины хэша.
+const (
+	SigAlgPKCS1v15   = "PKCS1v15"
+	SigAlgPSS        = "PSS"
+	SigAlgPSSMaxSalt = "PSS-MaxSalt"
+)
+
 const (
 	AESKeySize           = 32
 	HMACKeySize          = 32
@@ -29,18 +42,20 @@ var (
 	nonceMutex sync.RWMutex
 )
 
-// NonceTracker для управления nonce с улучшенной защитой
-type NonceTracker struct {
-	nonces          map[string]time.Time
+// MemoryNonceTracker — процесс-локальная реализация NonceTracker поверх
+// карты в памяти с фоновой очисткой записей по истечении их собственного
+// ttl (переданного в AddNonce, а не общего cleanupInterval на всю карту).
+type MemoryNonceTracker struct {
+	nonces          map[string]time.Time // nonce -> момент, когда запись считается истекшей
 	mutex           sync.RWMutex
 	maxSize         int
 	cleanupInterval time.Duration
 	stopChan        chan struct{}
 }
 
-// NewNonceTracker создает новый трекер nonce с автоматической очисткой
-func NewNonceTracker(maxSize int, cleanupInterval time.Duration) *NonceTracker {
-	tracker := &NonceTracker{
+// NewMemoryNonceTracker создает новый трекер nonce с автоматической очисткой
+func NewMemoryNonceTracker(maxSize int, cleanupInterval time.Duration) *MemoryNonceTracker {
+	tracker := &MemoryNonceTracker{
 		nonces:          make(map[string]time.Time),
 		maxSize:         maxSize,
 		cleanupInterval: cleanupInterval,
@@ -53,38 +68,39 @@ func NewNonceTracker(maxSize int, cleanupInterval time.Duration) *NonceTracker {
 	return tracker
 }
 
-// AddNonce добавляет nonce и проверяет на дубликаты
-func (nt *NonceTracker) AddNonce(nonce []byte) error {
+// AddNonce добавляет nonce со временем жизни ttl и проверяет на дубликаты —
+// запись, чей ttl уже истек, дубликатом не считается и перезаписывается.
+func (nt *MemoryNonceTracker) AddNonce(nonce []byte, ttl time.Duration) error {
 	nonceStr := string(nonce)
 	nt.mutex.Lock()
 	defer nt.mutex.Unlock()
 
-	// Проверяем на дубликат
-	if _, exists := nt.nonces[nonceStr]; exists {
+	// Проверяем на дубликат (если запись еще не истекла)
+	if expiresAt, exists := nt.nonces[nonceStr]; exists && time.Now().Before(expiresAt) {
 		return errors.New("nonce уже использован (replay attack обнаружен)")
 	}
 
 	// Проверяем размер и очищаем при необходимости
 	if len(nt.nonces) >= nt.maxSize {
-		nt.cleanupOldNonces()
+		nt.cleanupExpiredLocked()
 	}
 
-	nt.nonces[nonceStr] = time.Now()
+	nt.nonces[nonceStr] = time.Now().Add(ttl)
 	return nil
 }
 
-// cleanupOldNonces удаляет старые nonce
-func (nt *NonceTracker) cleanupOldNonces() {
-	cutoff := time.Now().Add(-nt.cleanupInterval)
-	for nonce, timestamp := range nt.nonces {
-		if timestamp.Before(cutoff) {
+// cleanupExpiredLocked удаляет записи с истекшим ttl
+func (nt *MemoryNonceTracker) cleanupExpiredLocked() {
+	now := time.Now()
+	for nonce, expiresAt := range nt.nonces {
+		if now.After(expiresAt) {
 			delete(nt.nonces, nonce)
 		}
 	}
 }
 
 // startCleanup запускает периодическую очистку
-func (nt *NonceTracker) startCleanup() {
+func (nt *MemoryNonceTracker) startCleanup() {
 	ticker := time.NewTicker(nt.cleanupInterval / 2) // Очистка в 2 раза чаще
 	defer ticker.Stop()
 
@@ -92,7 +108,7 @@ func (nt *NonceTracker) startCleanup() {
 		select {
 		case <-ticker.C:
 			nt.mutex.Lock()
-			nt.cleanupOldNonces()
+			nt.cleanupExpiredLocked()
 			nt.mutex.Unlock()
 		case <-nt.stopChan:
 			return
@@ -101,26 +117,55 @@ func (nt *NonceTracker) startCleanup() {
 }
 
 // Stop останавливает автоматическую очистку
-func (nt *NonceTracker) Stop() {
+func (nt *MemoryNonceTracker) Stop() {
 	close(nt.stopChan)
 }
 
-// GetCount возвращает количество сохраненных nonce
-func (nt *NonceTracker) GetCount() int {
+// Count возвращает количество сохраненных nonce
+func (nt *MemoryNonceTracker) Count() int {
 	nt.mutex.RLock()
 	defer nt.mutex.RUnlock()
 	return len(nt.nonces)
 }
 
 // Reset очищает все nonce
-func (nt *NonceTracker) Reset() {
+func (nt *MemoryNonceTracker) Reset() {
 	nt.mutex.Lock()
 	defer nt.mutex.Unlock()
 	nt.nonces = make(map[string]time.Time)
 }
 
-// Глобальный трекер nonce с улучшенной защитой
-var globalNonceTracker = NewNonceTracker(MaxNonceStorage, NonceCleanupInterval)
+// Глобальный трекер nonce с улучшенной защитой. Сохранен ради обратной
+// совместимости кода, который создает собственные MemoryNonceTracker —
+// глобальное хранилище, используемое VerifyAndDecryptMessage по умолчанию,
+// построено на ShardedNonceCache (см. noncecache.go и
+// shardedNonceCacheTracker ниже), дающем жесткий потолок памяти и
+// Bloom-фильтр на быстром пути (см. TestNonceExhaustionAttack).
+var globalNonceTracker = NewMemoryNonceTracker(MaxNonceStorage, NonceCleanupInterval)
+
+// globalNonceCache — основное хранилище предъявленных nonce, используемое
+// VerifyAndDecryptMessage (через shardedNonceCacheTracker) и публичными
+// обертками ClearOldNonces/GetNonceCount/ResetNonceStorage.
+var globalNonceCache = NewShardedNonceCache(DefaultNonceCacheConfig())
+
+// shardedNonceCacheTracker адаптирует ShardedNonceCache к интерфейсу
+// NonceTracker — backend по умолчанию в VerifyAndDecryptMessageWithConfig,
+// сохраняющий поведение (Bloom-фильтр + шардированный LRU с собственным
+// окном), каким оно было до появления пригодных для замены backend'ов;
+// ttl, передаваемый AddNonce, игнорируется, поскольку ShardedNonceCache
+// управляет своим окном самостоятельно (см. NonceCacheConfig.Window).
+type shardedNonceCacheTracker struct {
+	cache *ShardedNonceCache
+}
+
+func (t shardedNonceCacheTracker) AddNonce(nonce []byte, _ time.Duration) error {
+	return t.cache.AddNonce(nonce)
+}
+func (t shardedNonceCacheTracker) Count() int { return t.cache.Count() }
+func (t shardedNonceCacheTracker) Reset()     { t.cache.Reset() }
+func (t shardedNonceCacheTracker) Stop()      {}
+
+var globalNonceCacheTracker NonceTracker = shardedNonceCacheTracker{cache: globalNonceCache}
 
 type Message struct {
 	Timestamp int64  // Временная метка
@@ -131,18 +176,153 @@ type Message struct {
 	Signature []byte // Подпись ECDSA
 	PubKey    []byte // Публичный ключ ECDH
 	RSASig    []byte // Подпись RSA
+	Epoch     uint64 // Эпоха ключей сессии (увеличивается при rekey)
+	SigAlg    string // Схема RSA-подписи: SigAlgPKCS1v15, SigAlgPSS или SigAlgPSSMaxSalt
+
+	// CipherSuite — набор шифрования полезной нагрузки сообщения:
+	// SuiteAESCBCHMAC (легаси, AES-256-CBC + HMAC-SHA256, encrypt-then-MAC),
+	// SuiteNaClSecretboxAuth (XSalsa20-Poly1305 + nacl/auth) или, для
+	// сообщений CreateSecureMessageAEAD, SuiteAES256GCM/SuiteChaCha20Poly1305.
+	// Все конструкторы проставляют его явно.
+	CipherSuite CipherSuite
+
+	// SealedDEK, если не nil, означает, что Cipher/HMAC зашифрованы и
+	// аутентифицированы одноразовым data encryption key (DEK), запечатанным
+	// в этом поле долгоживущим sharedSecret (см. CreateSecureMessageEnvelope,
+	// SealDEK/UnsealDEK), а не напрямую sharedSecret. nil сохраняет
+	// поведение legacy-сообщений, где sharedSecret используется напрямую.
+	SealedDEK *SealedDEK
+
+	// RatchetHeader, если не nil, означает, что сообщение создано и должно
+	// расшифровываться через (*Ratchet).Encrypt/Decrypt (см. ratchet.go), а
+	// не через CreateSecureMessage*/VerifyAndDecryptMessage — Cipher/HMAC/IV
+	// этого сообщения зашифрованы ключом, выведенным из цепочки Double
+	// Ratchet, а не из sharedSecret или DEK напрямую.
+	RatchetHeader *RatchetHeader
+
+	// MACAlgo — идентификатор реализации MAC (см. MAC, macByAlgo в mac.go),
+	// которой проверяется Cipher для CipherSuite(ов), использующих HMAC-подобную
+	// схему (сейчас — только SuiteAESCBCHMAC; SuiteNaClSecretboxAuth всегда
+	// аутентифицируется nacl/auth независимо от этого поля). Нулевое значение
+	// MACAlgoHMACSHA256 сохраняет поведение сообщений, созданных до появления
+	// этого поля.
+	MACAlgo byte
+
+	// NonceTag — keyed-BLAKE2b тег поверх (Nonce ‖ Timestamp), см.
+	// computeNonceTag/deriveNonceObfuscationKey в mac.go. Пустой для сообщений,
+	// созданных до появления этого поля, либо для конструкций (AEAD, Ratchet),
+	// у которых Nonce и так уже аутентифицирован иначе — VerifyAndDecryptMessage
+	// проверяет его, только если он не пуст.
+	NonceTag []byte
+
+	// Signatures — необязательный список подписей (см. SignedBy, SigAlgo в
+	// signatures.go), позволяющий вызывающей стороне потребовать N-of-M
+	// подписей (VerifyThresholdSignatures) вместо ровно одной ECDSA- и одной
+	// RSA-подписи, как в legacy-полях Signature/RSASig. Пуст для сообщений,
+	// не использующих схему N-of-M — VerifyAndDecryptMessage его не трогает,
+	// проверка остается на усмотрение вызывающей стороны.
+	Signatures []SignedBy
 }
 
 func CreateSecureMessage(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, stats *metrics.SecurityStats) Message {
-	iv := make([]byte, 16)
-	rand.Read(iv)
-	ciphertext := AESEncrypt(sharedSecret[:AESKeySize], iv, plaintext, stats)
-	hmacValue := GenerateHMAC(sharedSecret[AESKeySize:], ciphertext)
+	msg, err := CreateSecureMessageWithSigAlg(plaintext, sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, SigAlgPKCS1v15, stats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return msg
+}
+
+// CreateSecureMessageWithSigAlg собирает защищенное сообщение, используя
+// согласованную во время обмена ключами схему RSA-подписи (PKCS1v15 или PSS)
+// и набор шифров SuiteAESCBCHMAC (легаси-конструкция) с DefaultRand в
+// качестве источника энтропии для IV и nonce.
+func CreateSecureMessageWithSigAlg(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, stats *metrics.SecurityStats) (Message, error) {
+	return CreateSecureMessageWithRand(plaintext, sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, sigAlg, DefaultRand, stats)
+}
+
+// CreateSecureMessageWithRand собирает защищенное сообщение, читая IV и nonce
+// из переданного rnd вместо DefaultRand. Позволяет внедрить детерминированный
+// источник (см. NewDeterministicReader), чтобы делать результаты
+// timing/replay attack-тестов воспроизводимыми в CI.
+func CreateSecureMessageWithRand(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	return CreateSecureMessageWithSuite(plaintext, sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, sigAlg, SuiteAESCBCHMAC, rnd, stats)
+}
+
+// CreateSecureMessageWithSuite собирает защищенное сообщение, шифруя
+// полезную нагрузку согласованным набором шифров suite (SuiteAESCBCHMAC —
+// легаси AES-256-CBC + HMAC-SHA256, либо SuiteNaClSecretboxAuth —
+// XSalsa20-Poly1305 + nacl/auth) и читая IV/nonce из rnd. Для
+// SuiteNaClSecretboxAuth sharedSecret делится так же, как для
+// SuiteAESCBCHMAC: первые AESKeySize байт — ключ шифрования, оставшиеся —
+// ключ аутентификации. Эквивалентно CreateSecureMessageWithMAC с
+// MACAlgoHMACSHA256.
+func CreateSecureMessageWithSuite(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, suite CipherSuite, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	return CreateSecureMessageWithMAC(plaintext, sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, sigAlg, suite, MACAlgoHMACSHA256, rnd, stats)
+}
+
+// CreateSecureMessageWithMAC собирает защищенное сообщение, как и
+// CreateSecureMessageWithSuite, но позволяет выбрать реализацию MAC (см.
+// MAC, macByAlgo в mac.go), которой аутентифицируется Cipher для
+// SuiteAESCBCHMAC — MACAlgoHMACSHA256 (легаси HMAC-SHA256) или
+// MACAlgoBLAKE2b (keyed BLAKE2b-256). SuiteNaClSecretboxAuth по-прежнему
+// аутентифицируется только nacl/auth, независимо от macAlgo. Также выводит
+// Message.NonceTag — keyed-BLAKE2b обязательство поверх (Nonce ‖ Timestamp),
+// привязывающее их друг к другу (см. computeNonceTag).
+func CreateSecureMessageWithMAC(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, suite CipherSuite, macAlgo byte, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	mac, err := macByAlgo(macAlgo)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var iv, ciphertext, hmacValue []byte
+
+	switch suite {
+	case SuiteNaClSecretboxAuth:
+		iv = make([]byte, SecretboxNonceSize)
+		io.ReadFull(rnd, iv)
+		ciphertext = SecretboxEncrypt(sharedSecret[:AESKeySize], iv, plaintext, stats)
+		hmacValue = NaClAuth(sharedSecret[AESKeySize:], ciphertext)
+	default:
+		suite = SuiteAESCBCHMAC
+		iv = make([]byte, 16)
+		io.ReadFull(rnd, iv)
+		ciphertext = AESEncrypt(sharedSecret[:AESKeySize], iv, plaintext, stats)
+		hmacValue = mac.Sum(sharedSecret[AESKeySize:], ciphertext)
+	}
+	if stats != nil {
+		stats.RecordCipherSuiteUsage(suite.String())
+	}
 	ecdsaSig := SignECDSA(ecdsaPriv, ciphertext, stats)
-	rsaSig := SignRSA(rsaPriv, ciphertext, stats)
+
+	var rsaSig []byte
+	switch sigAlg {
+	case SigAlgPSS:
+		rsaSig, err = SignRSAPSS(rsaPriv, ciphertext, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS подписи: %w", err)
+		}
+	case SigAlgPSSMaxSalt:
+		rsaSig, err = SignRSAPSSWithSaltLength(rsaPriv, ciphertext, rsa.PSSSaltLengthAuto, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS (max salt) подписи: %w", err)
+		}
+	case SigAlgPKCS1v15, "":
+		rsaSig = SignRSA(rsaPriv, ciphertext, stats)
+		sigAlg = SigAlgPKCS1v15
+	default:
+		return Message{}, fmt.Errorf("неизвестная схема подписи RSA: %s", sigAlg)
+	}
+
 	nonce := make([]byte, NonceSize)
-	rand.Read(nonce)
+	io.ReadFull(rnd, nonce)
 	timestamp := time.Now().Unix()
+
+	nonceObfKey, err := deriveNonceObfuscationKey(sharedSecret)
+	if err != nil {
+		return Message{}, fmt.Errorf("ошибка деривации ключа обфускации nonce: %w", err)
+	}
+	nonceTag := computeNonceTag(nonceObfKey, nonce, timestamp)
+
 	return Message{
 		Timestamp: timestamp,
 		Nonce:     nonce,
@@ -152,24 +332,217 @@ func CreateSecureMessage(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa
 		Signature: ecdsaSig,
 		PubKey:    ecdhPub,
 		RSASig:    rsaSig,
+		SigAlg:    sigAlg,
+
+		CipherSuite: suite,
+		MACAlgo:     macAlgo,
+		NonceTag:    nonceTag,
+	}, nil
+}
+
+// CreateSecureMessageStream собирает защищенное сообщение из plaintext,
+// читаемого потоком, а не готовым срезом байт — удобно, когда данные приходят
+// из сети или файла и их неудобно собирать в []byte на вызывающей стороне.
+//
+// ВАЖНО: Message — монолитная конструкция (один IV/Cipher/HMAC/Signature на
+// сообщение), поэтому для вычисления HMAC/ECDSA/RSA-подписи по всему
+// ciphertext plaintext все равно буферизуется целиком через io.ReadAll, прежде
+// чем передать его в CreateSecureMessageWithSuite. Для действительно
+// потокового (чанкового) шифрования больших payload'ов без полной
+// буферизации используйте NewEncryptingWriter/NewDecryptingReader (stream.go)
+// — они работают вне конверта Message.
+func CreateSecureMessageStream(r io.Reader, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, suite CipherSuite, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return Message{}, fmt.Errorf("ошибка чтения потока plaintext: %w", err)
+	}
+	return CreateSecureMessageWithSuite(plaintext, sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, sigAlg, suite, rnd, stats)
+}
+
+// CreateSecureMessageEnvelope собирает защищенное сообщение, используя
+// envelope-шифрование: полезная нагрузка шифруется одноразовым DEK (data
+// encryption key), сгенерированным заново для этого сообщения, а сам DEK
+// запечатывается долгоживущим sharedSecret (SealDEK) и кладется в
+// Message.SealedDEK. В отличие от CreateSecureMessageWithSuite, где
+// sharedSecret используется напрямую как ключ шифрования на все время жизни
+// сессии, компрометация DEK одного сообщения не раскрывает остальные — их
+// DEK независимы и распечатываются из своих собственных SealedDEK.
+func CreateSecureMessageEnvelope(plaintext []byte, sharedSecret []byte, ecdsaPriv *ecdsa.PrivateKey, ecdhPub []byte, rsaPriv *rsa.PrivateKey, sigAlg string, rnd io.Reader, stats *metrics.SecurityStats) (Message, error) {
+	dek, err := GenerateDEKWithRand(rnd)
+	if err != nil {
+		return Message{}, fmt.Errorf("ошибка генерации DEK: %w", err)
+	}
+	encKey, macKey, err := DeriveDEKSubkeys(dek)
+	if err != nil {
+		return Message{}, fmt.Errorf("ошибка деривации ключей DEK: %w", err)
+	}
+	sealed, err := SealDEK(dek, sharedSecret, rnd, stats)
+	if err != nil {
+		return Message{}, fmt.Errorf("ошибка запечатывания DEK: %w", err)
+	}
+
+	iv := make([]byte, 16)
+	io.ReadFull(rnd, iv)
+	ciphertext := AESEncrypt(encKey, iv, plaintext, stats)
+	hmacValue := GenerateHMAC(macKey, ciphertext)
+	if stats != nil {
+		stats.RecordCipherSuiteUsage(SuiteAESCBCHMAC.String())
+	}
+
+	ecdsaSig := SignECDSA(ecdsaPriv, ciphertext, stats)
+
+	var rsaSig []byte
+	switch sigAlg {
+	case SigAlgPSS:
+		rsaSig, err = SignRSAPSS(rsaPriv, ciphertext, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS подписи: %w", err)
+		}
+	case SigAlgPSSMaxSalt:
+		rsaSig, err = SignRSAPSSWithSaltLength(rsaPriv, ciphertext, rsa.PSSSaltLengthAuto, stats)
+		if err != nil {
+			return Message{}, fmt.Errorf("ошибка RSA-PSS (max salt) подписи: %w", err)
+		}
+	case SigAlgPKCS1v15, "":
+		rsaSig = SignRSA(rsaPriv, ciphertext, stats)
+		sigAlg = SigAlgPKCS1v15
+	default:
+		return Message{}, fmt.Errorf("неизвестная схема подписи RSA: %s", sigAlg)
+	}
+
+	nonce := make([]byte, NonceSize)
+	io.ReadFull(rnd, nonce)
+	return Message{
+		Timestamp:   time.Now().Unix(),
+		Nonce:       nonce,
+		IV:          iv,
+		Cipher:      ciphertext,
+		HMAC:        hmacValue,
+		Signature:   ecdsaSig,
+		PubKey:      ecdhPub,
+		RSASig:      rsaSig,
+		SigAlg:      sigAlg,
+		CipherSuite: SuiteAESCBCHMAC,
+		SealedDEK:   &sealed,
+	}, nil
+}
+
+// VerifyConfig собирает необязательные параметры
+// VerifyAndDecryptMessageWithConfig, настраиваемые через VerifyOption.
+type VerifyConfig struct {
+	tracker  NonceTracker
+	nonceTTL time.Duration
+}
+
+// VerifyOption настраивает VerifyConfig функциональным опционом.
+type VerifyOption func(*VerifyConfig)
+
+// WithNonceTracker заменяет хранилище предъявленных nonce, используемое по
+// умолчанию (shardedNonceCacheTracker поверх globalNonceCache), на
+// произвольную реализацию NonceTracker — например, RedisNonceTracker или
+// BoltNonceTracker, общую для нескольких экземпляров сервера, разделяющих
+// один sharedSecret.
+func WithNonceTracker(tracker NonceTracker) VerifyOption {
+	return func(c *VerifyConfig) { c.tracker = tracker }
+}
+
+// WithNonceTTL задает время жизни записи о предъявленном nonce, передаваемое
+// в NonceTracker.AddNonce. По умолчанию 2*MaxTimeDifference секунд — тот же
+// запас, что и в DefaultNonceCacheConfig (см. noncecache.go).
+func WithNonceTTL(ttl time.Duration) VerifyOption {
+	return func(c *VerifyConfig) { c.nonceTTL = ttl }
+}
+
+func defaultVerifyConfig() VerifyConfig {
+	return VerifyConfig{
+		tracker:  globalNonceCacheTracker,
+		nonceTTL: 2 * MaxTimeDifference * time.Second,
 	}
 }
 
+// VerifyAndDecryptMessage проверяет и расшифровывает сообщение, используя
+// хранилище nonce по умолчанию (globalNonceCache). Эквивалентно
+// VerifyAndDecryptMessageWithConfig без опций.
 func VerifyAndDecryptMessage(msg Message, sharedSecret []byte, rsaPubKey []byte, stats *metrics.SecurityStats) ([]byte, error) {
+	return VerifyAndDecryptMessageWithConfig(msg, sharedSecret, rsaPubKey, stats)
+}
+
+// VerifyAndDecryptMessageWithConfig — как VerifyAndDecryptMessage, но
+// позволяет настроить хранилище nonce через функциональные опции (см.
+// WithNonceTracker, WithNonceTTL) вместо жестко заданного globalNonceCache —
+// это нужно, чтобы несколько экземпляров сервера, разделяющих sharedSecret,
+// могли согласованно отклонять replay через общий backend (см.
+// RedisNonceTracker/BoltNonceTracker), и чтобы тесты могли подставлять
+// изолированный трекер для каждого сценария (см. testReplayAttackPrevention).
+func VerifyAndDecryptMessageWithConfig(msg Message, sharedSecret []byte, rsaPubKey []byte, stats *metrics.SecurityStats, opts ...VerifyOption) ([]byte, error) {
+	cfg := defaultVerifyConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	// 1. Проверка timestamp (сокращенное окно для лучшей безопасности)
 	now := time.Now().Unix()
 	if now-msg.Timestamp > MaxTimeDifference || now < msg.Timestamp-MaxTimeDifference {
 		return nil, errors.New("временная метка вне допустимого диапазона")
 	}
 
-	// 2. Улучшенная проверка nonce с использованием глобального трекера
-	if err := globalNonceTracker.AddNonce(msg.Nonce); err != nil {
+	// 2. Улучшенная проверка nonce через настроенный NonceTracker (по
+	// умолчанию — глобальный шардированный кэш, см. WithNonceTracker).
+	if err := cfg.tracker.AddNonce(msg.Nonce, cfg.nonceTTL); err != nil {
 		return nil, err
 	}
 
-	// 3. Проверка HMAC (с constant-time сравнением)
-	if !VerifyHMAC(sharedSecret[AESKeySize:], msg.Cipher, msg.HMAC) {
-		return nil, errors.New("проверка HMAC не удалась")
+	// 2а. Если сообщение несет NonceTag (см. CreateSecureMessageWithMAC),
+	// проверяем, что Nonce действительно был привязан к этому Timestamp тем,
+	// кто знает sharedSecret — иначе не проверяем (NonceTag пуст у сообщений,
+	// созданных до появления этого поля, либо у AEAD/Ratchet/Envelope, уже
+	// аутентифицирующих Nonce иначе).
+	if len(msg.NonceTag) > 0 {
+		nonceObfKey, err := deriveNonceObfuscationKey(sharedSecret)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка деривации ключа обфускации nonce: %w", err)
+		}
+		expectedTag := computeNonceTag(nonceObfKey, msg.Nonce, msg.Timestamp)
+		if subtle.ConstantTimeCompare(expectedTag, msg.NonceTag) != 1 {
+			return nil, errors.New("проверка обфусцированного nonce (NonceTag) не удалась")
+		}
+	}
+
+	// 2б. Envelope-шифрование (см. CreateSecureMessageEnvelope): если DEK
+	// сообщения запечатан, сначала распечатываем его sharedSecret и выводим
+	// из него ключи шифрования/аутентификации, которые используются ниже
+	// вместо sharedSecret напрямую.
+	encKey := sharedSecret[:AESKeySize]
+	macKey := sharedSecret[AESKeySize:]
+	if msg.SealedDEK != nil {
+		dek, err := UnsealDEK(*msg.SealedDEK, sharedSecret, stats)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка распечатывания DEK: %w", err)
+		}
+		encKey, macKey, err = DeriveDEKSubkeys(dek)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка деривации ключей DEK: %w", err)
+		}
+	}
+
+	// 3. Проверка аутентификации шифротекста (с constant-time сравнением).
+	// Схема выбирается по msg.CipherSuite: nacl/auth для
+	// SuiteNaClSecretboxAuth, либо реализация MAC, указанная в msg.MACAlgo
+	// (см. macByAlgo в mac.go — HMAC-SHA256 по умолчанию, либо BLAKE2b).
+	switch msg.CipherSuite {
+	case SuiteNaClSecretboxAuth:
+		authValid, _, _ := NaClVerify(macKey, msg.Cipher, msg.HMAC)
+		if !authValid {
+			return nil, errors.New("проверка NaCl-аутентификации не удалась")
+		}
+	default:
+		mac, err := macByAlgo(msg.MACAlgo)
+		if err != nil {
+			return nil, err
+		}
+		if !mac.Verify(macKey, msg.Cipher, msg.HMAC) {
+			return nil, errors.New("проверка MAC не удалась")
+		}
 	}
 
 	// 4. Проверка ECDSA подписи
@@ -177,16 +550,36 @@ func VerifyAndDecryptMessage(msg Message, sharedSecret []byte, rsaPubKey []byte,
 		return nil, errors.New("ECDSA-подпись недействительна")
 	}
 
-	// 5. Проверка RSA подписи
-	if !VerifyRSA(rsaPubKey, msg.Cipher, msg.RSASig, stats) {
+	// 5. Проверка RSA подписи (схема выбирается по msg.SigAlg для legacy-совместимости)
+	rsaValid := false
+	switch msg.SigAlg {
+	case SigAlgPSS:
+		rsaValid = VerifyRSAPSS(rsaPubKey, msg.Cipher, msg.RSASig, stats)
+	case SigAlgPSSMaxSalt:
+		rsaValid = VerifyRSAPSSWithSaltLength(rsaPubKey, msg.Cipher, msg.RSASig, rsa.PSSSaltLengthAuto, stats)
+	default:
+		rsaValid = VerifyRSA(rsaPubKey, msg.Cipher, msg.RSASig, stats)
+	}
+	if !rsaValid {
 		return nil, errors.New("RSA-подпись недействительна")
 	}
 
-	// 6. Расшифровка данных
-	plaintext, err := AESDecrypt(sharedSecret[:AESKeySize], msg.IV, msg.Cipher, stats)
+	// 6. Расшифровка данных согласованным набором шифров
+	var plaintext []byte
+	var err error
+	switch msg.CipherSuite {
+	case SuiteNaClSecretboxAuth:
+		plaintext, err = SecretboxDecrypt(encKey, msg.IV, msg.Cipher, stats)
+	default:
+		plaintext, err = AESDecrypt(encKey, msg.IV, msg.Cipher, stats)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка расшифровки: %v", err)
 	}
+
+	if stats != nil {
+		stats.RecordCipherSuiteUsage(msg.CipherSuite.String())
+	}
 	return plaintext, nil
 }
 
@@ -203,17 +596,23 @@ func ComputeSharedSecret(priv *ecdsa.PrivateKey, peerPubBytes []byte) []byte {
 
 // ClearOldNonces очищает устаревшие nonce из памяти
 func ClearOldNonces() {
-	globalNonceTracker.mutex.Lock()
-	defer globalNonceTracker.mutex.Unlock()
-	globalNonceTracker.cleanupOldNonces()
+	globalNonceCache.EvictExpired()
 }
 
 // GetNonceCount возвращает количество сохраненных nonce (для тестирования)
 func GetNonceCount() int {
-	return globalNonceTracker.GetCount()
+	return globalNonceCache.Count()
 }
 
 // ResetNonceStorage очищает все nonce (для тестирования)
 func ResetNonceStorage() {
-	globalNonceTracker.Reset()
+	globalNonceCache.Reset()
+}
+
+// GetNonceCache возвращает глобальный ShardedNonceCache, используемый
+// VerifyAndDecryptMessage — экспортирован для тестирования (см.
+// TestNonceExhaustionAttack), которому нужен прямой доступ к AddNonce/Count
+// в обход полного цикла VerifyAndDecryptMessage.
+func GetNonceCache() *ShardedNonceCache {
+	return globalNonceCache
 }
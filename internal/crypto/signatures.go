@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"client-server/tests/metrics"
+)
+
+// SigAlgo — идентификатор алгоритма подписи, используемый в SignedBy.
+// Нулевое значение SigAlgoUnspecified не встречается в валидном SignedBy —
+// вызывающая сторона обязана явно проставить один из остальных вариантов.
+type SigAlgo int
+
+const (
+	SigAlgoUnspecified SigAlgo = iota
+	SigAlgoECDSAP256
+	SigAlgoRSAPKCS1v15SHA256
+	SigAlgoRSAPSSSHA256
+	SigAlgoEd25519
+)
+
+func (a SigAlgo) String() string {
+	switch a {
+	case SigAlgoECDSAP256:
+		return "ECDSA-P256"
+	case SigAlgoRSAPKCS1v15SHA256:
+		return "RSA-PKCS1v15-SHA256"
+	case SigAlgoRSAPSSSHA256:
+		return "RSA-PSS-SHA256"
+	case SigAlgoEd25519:
+		return "Ed25519"
+	default:
+		return "неизвестный SigAlgo"
+	}
+}
+
+// SignedBy — одна подпись данных сообщения вместе с указанием алгоритма и
+// открытого ключа подписавшего. Message.Signatures хранит срез SignedBy,
+// что позволяет требовать N-of-M подписей вместо ровно одной ECDSA- и одной
+// RSA-подписи, как это делает legacy-путь (Message.Signature/Message.RSASig).
+type SignedBy struct {
+	Algo      SigAlgo
+	PubKey    []byte
+	Signature []byte
+}
+
+// SignBy создает SignedBy для данных data, используя алгоритм algo и
+// соответствующий ему приватный ключ priv (*ecdsa.PrivateKey для
+// SigAlgoECDSAP256, *rsa.PrivateKey для SigAlgoRSAPKCS1v15SHA256/
+// SigAlgoRSAPSSSHA256, ed25519.PrivateKey для SigAlgoEd25519). pubBytes —
+// PKIX-кодирование соответствующего открытого ключа, сохраняемое в
+// SignedBy.PubKey для последующей проверки функцией VerifySignedBy.
+func SignBy(algo SigAlgo, priv interface{}, pubBytes, data []byte, stats *metrics.SecurityStats) (SignedBy, error) {
+	switch algo {
+	case SigAlgoECDSAP256:
+		ecdsaPriv, ok := priv.(*ecdsa.PrivateKey)
+		if !ok {
+			return SignedBy{}, fmt.Errorf("SignBy: %s требует *ecdsa.PrivateKey", algo)
+		}
+		return SignedBy{Algo: algo, PubKey: pubBytes, Signature: SignECDSA(ecdsaPriv, data, stats)}, nil
+	case SigAlgoRSAPKCS1v15SHA256:
+		rsaPriv, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return SignedBy{}, fmt.Errorf("SignBy: %s требует *rsa.PrivateKey", algo)
+		}
+		return SignedBy{Algo: algo, PubKey: pubBytes, Signature: SignRSA(rsaPriv, data, stats)}, nil
+	case SigAlgoRSAPSSSHA256:
+		rsaPriv, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return SignedBy{}, fmt.Errorf("SignBy: %s требует *rsa.PrivateKey", algo)
+		}
+		sig, err := SignRSAPSS(rsaPriv, data, stats)
+		if err != nil {
+			return SignedBy{}, fmt.Errorf("SignBy: ошибка RSA-PSS подписи: %w", err)
+		}
+		return SignedBy{Algo: algo, PubKey: pubBytes, Signature: sig}, nil
+	case SigAlgoEd25519:
+		ed25519Priv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return SignedBy{}, fmt.Errorf("SignBy: %s требует ed25519.PrivateKey", algo)
+		}
+		return SignedBy{Algo: algo, PubKey: pubBytes, Signature: SignEd25519(ed25519Priv, data, stats)}, nil
+	default:
+		return SignedBy{}, fmt.Errorf("SignBy: неизвестный SigAlgo: %d", algo)
+	}
+}
+
+// VerifySignedBy проверяет одну подпись s над data, выбирая реализацию
+// проверки по s.Algo.
+func VerifySignedBy(s SignedBy, data []byte, stats *metrics.SecurityStats) bool {
+	switch s.Algo {
+	case SigAlgoECDSAP256:
+		return VerifyECDSA(s.PubKey, data, s.Signature, stats)
+	case SigAlgoRSAPKCS1v15SHA256:
+		return VerifyRSA(s.PubKey, data, s.Signature, stats)
+	case SigAlgoRSAPSSSHA256:
+		return VerifyRSAPSS(s.PubKey, data, s.Signature, stats)
+	case SigAlgoEd25519:
+		return VerifyEd25519(s.PubKey, data, s.Signature, stats)
+	default:
+		return false
+	}
+}
+
+// VerifyThresholdSignatures проверяет, что не менее threshold подписей из
+// sigs действительны для data — позволяет требовать N-of-M подписей (см.
+// Message.Signatures) вместо того, чтобы каждая без исключения подпись была
+// обязательной.
+func VerifyThresholdSignatures(data []byte, sigs []SignedBy, threshold int, stats *metrics.SecurityStats) bool {
+	if threshold <= 0 {
+		return true
+	}
+	valid := 0
+	for _, s := range sigs {
+		if VerifySignedBy(s, data, stats) {
+			valid++
+			if valid >= threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,371 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"client-server/tests/metrics"
+)
+
+// StreamChunkSize — размер открытого текста одного чанка потокового шифрования.
+// Ограничивает объем plaintext/ciphertext, который должен одновременно
+// находиться в памяти, в отличие от AESEncrypt/AESDecrypt, работающих с целым
+// буфером.
+const StreamChunkSize = 64 * 1024
+
+// streamAADSize — размер AAD чанка: 8 байт big-endian номера
+// последовательности + 1 байт флага последнего чанка.
+const streamAADSize = 9
+
+var (
+	// ErrStreamTruncated возвращается, если поток оборвался до чанка с
+	// выставленным флагом "последний" — признак truncation-атаки.
+	ErrStreamTruncated = errors.New("поток шифрования: обнаружено усечение (отсутствует финальный чанк)")
+	// ErrStreamTampered возвращается, если AES-GCM не смог аутентифицировать чанк.
+	ErrStreamTampered = errors.New("поток шифрования: чанк поврежден или подделан")
+)
+
+// encryptingWriter шифрует записываемые данные чанками по StreamChunkSize
+// байт, используя AES-256-GCM с AAD, включающим номер последовательности
+// чанка и флаг последнего чанка — это не позволяет переставить местами,
+// вырезать или повторно использовать чанки из другого потока.
+type encryptingWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	baseIV    []byte
+	chunkSize int
+	seq       uint64
+	buf       []byte
+	stats     *metrics.SecurityStats
+	closed    bool
+}
+
+// NewEncryptingWriter оборачивает w, возвращая io.WriteCloser, который
+// шифрует данные отдельными чанками по StreamChunkSize байт AES-256-GCM.
+// Close обязателен: он дошифровывает оставшийся буфер как финальный чанк с
+// выставленным флагом last-chunk, без которого NewDecryptingReader вернет
+// ErrStreamTruncated.
+func NewEncryptingWriter(w io.Writer, key, iv []byte, stats *metrics.SecurityStats) (io.WriteCloser, error) {
+	return NewEncryptingWriterWithChunkSize(w, key, iv, StreamChunkSize, stats)
+}
+
+// NewEncryptingWriterWithChunkSize — вариант NewEncryptingWriter с настраиваемым
+// размером чанка plaintext (например, для сравнения пропускной способности при
+// разных chunkSize в нагрузочных тестах, см. tests/benchmark). NewDecryptingReader
+// не нуждается в парном варианте: длина каждого чанка читается из его заголовка,
+// поэтому он прозрачно работает с любым chunkSize, которым был зашифрован поток.
+func NewEncryptingWriterWithChunkSize(w io.Writer, key, iv []byte, chunkSize int, stats *metrics.SecurityStats) (io.WriteCloser, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("поток шифрования: chunkSize должен быть положительным")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead, baseIV: iv, chunkSize: chunkSize, stats: stats}, nil
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, errors.New("поток шифрования: запись после Close")
+	}
+	total := len(p)
+	ew.buf = append(ew.buf, p...)
+	for len(ew.buf) >= ew.chunkSize {
+		if err := ew.writeChunk(ew.buf[:ew.chunkSize], false); err != nil {
+			return 0, err
+		}
+		ew.buf = ew.buf[ew.chunkSize:]
+	}
+	return total, nil
+}
+
+// Close дошифровывает накопленный остаток как финальный чанк, помечая его
+// флагом last-chunk, и больше не принимает записи.
+func (ew *encryptingWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	return ew.writeChunk(ew.buf, true)
+}
+
+func (ew *encryptingWriter) writeChunk(plaintext []byte, last bool) error {
+	nonce := chunkNonce(ew.aead.NonceSize(), ew.baseIV, ew.seq)
+	aad := chunkAAD(ew.seq, last)
+
+	start := time.Now()
+	ciphertext := ew.aead.Seal(nil, nonce, plaintext, aad)
+	if ew.stats != nil {
+		ew.stats.RecordEncryptionTime(time.Since(start))
+	}
+
+	lastByte := byte(0)
+	if last {
+		lastByte = 1
+	}
+	var header [5]byte
+	header[0] = lastByte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(ciphertext)))
+
+	if _, err := ew.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := ew.w.Write(ciphertext); err != nil {
+		return err
+	}
+	ew.seq++
+	return nil
+}
+
+// decryptingReader проверяет и расшифровывает чанки, записанные
+// encryptingWriter, и возвращает ErrStreamTruncated, если поток обрывается
+// раньше, чем прочитан чанк с флагом последнего.
+type decryptingReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	baseIV  []byte
+	seq     uint64
+	buf     []byte
+	stats   *metrics.SecurityStats
+	done    bool
+	sawLast bool
+}
+
+// NewDecryptingReader оборачивает r, возвращая io.ReadCloser, который
+// расшифровывает чанки, созданные NewEncryptingWriter, и прерывает чтение с
+// ErrStreamTampered либо ErrStreamTruncated при первом поврежденном или
+// недостающем чанке, не отдавая наружу частичный расшифрованный текст сверх
+// уже проверенных чанков.
+func NewDecryptingReader(r io.Reader, key, iv []byte, stats *metrics.SecurityStats) (io.ReadCloser, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: r, aead: aead, baseIV: iv, stats: stats}, nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+
+		plaintext, last, err := dr.readChunk()
+		if err != nil {
+			dr.done = true
+			return 0, err
+		}
+
+		dr.buf = plaintext
+		if last {
+			dr.sawLast = true
+			dr.done = true
+		}
+	}
+
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
+
+func (dr *decryptingReader) readChunk() ([]byte, bool, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(dr.r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, false, ErrStreamTruncated
+		}
+		return nil, false, ErrStreamTruncated
+	}
+	last := header[0] == 1
+	clen := binary.BigEndian.Uint32(header[1:])
+
+	ciphertext := make([]byte, clen)
+	if _, err := io.ReadFull(dr.r, ciphertext); err != nil {
+		return nil, false, ErrStreamTruncated
+	}
+
+	nonce := chunkNonce(dr.aead.NonceSize(), dr.baseIV, dr.seq)
+	aad := chunkAAD(dr.seq, last)
+
+	start := time.Now()
+	plaintext, err := dr.aead.Open(nil, nonce, ciphertext, aad)
+	if dr.stats != nil {
+		dr.stats.RecordDecryptionTime(time.Since(start))
+	}
+	if err != nil {
+		return nil, false, ErrStreamTampered
+	}
+
+	dr.seq++
+	return plaintext, last, nil
+}
+
+// Close для decryptingReader не требует собственной логики — поток
+// завершается проверкой флага последнего чанка внутри Read.
+func (dr *decryptingReader) Close() error {
+	return nil
+}
+
+// chunkNonce строит nonce AEAD как XOR базового IV с номером
+// последовательности чанка — по аналогии с DirectionalKeys.NextNonce в aead.go.
+func chunkNonce(size int, baseIV []byte, seq uint64) []byte {
+	nonce := make([]byte, size)
+	copy(nonce, baseIV)
+	var seqBytes [8]byte
+	binary.BigEndian.PutUint64(seqBytes[:], seq)
+	for i := 0; i < 8 && i < size; i++ {
+		nonce[size-8+i] ^= seqBytes[i]
+	}
+	return nonce
+}
+
+// chunkAAD строит AAD чанка: номер последовательности + флаг последнего
+// чанка. Флаг передается в открытом виде перед длиной чанка (см. writeChunk),
+// но включение его в AAD гарантирует, что подмена этого публичного байта
+// нарушит аутентификацию чанка.
+func chunkAAD(seq uint64, last bool) []byte {
+	aad := make([]byte, streamAADSize)
+	binary.BigEndian.PutUint64(aad[:8], seq)
+	if last {
+		aad[8] = 1
+	}
+	return aad
+}
+
+// streamIVSize — размер IV, который NewEncryptWriter генерирует сам и
+// отправляет получателю перед зашифрованными чанками.
+const streamIVSize = 12
+
+// streamDefaultChunkSize — размер чанка открытого текста для
+// NewEncryptWriter/NewDecryptReader: меньше StreamChunkSize, рассчитан на
+// передачу файлов по протоколу client-server, где каждый чанк и так
+// буферизуется целиком на обеих сторонах TCP-соединения.
+const streamDefaultChunkSize = 16 * 1024
+
+// NewEncryptWriter — вариант NewEncryptingWriterWithChunkSize для передачи
+// файлов по протоколу client-server: сам генерирует случайный IV через
+// DefaultRand и пишет его получателю перед зашифрованными чанками, вместо
+// того чтобы требовать IV от вызывающей стороны, режет поток на чанки по
+// streamDefaultChunkSize и резервирует слот ConcurrencyCheck один раз на весь
+// поток, а не на чанк (nonce каждого чанка все равно получается из этого IV
+// плюс монотонно растущий seq, см. chunkNonce). Накопленная длительность
+// всего потока (включая ожидание ConcurrencyCheck) попадает в
+// stats.RecordEncryptionTime и ConcurrencyRelease только в Close.
+func NewEncryptWriter(w io.Writer, key []byte, stats *metrics.SecurityStats) (io.WriteCloser, error) {
+	start := time.Now()
+	if err := ConcurrencyCheck(stats); err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, streamIVSize)
+	if _, err := io.ReadFull(DefaultRand, iv); err != nil {
+		ConcurrencyRelease(0)
+		return nil, err
+	}
+	if _, err := w.Write(iv); err != nil {
+		ConcurrencyRelease(0)
+		return nil, err
+	}
+
+	inner, err := NewEncryptingWriterWithChunkSize(w, key, iv, streamDefaultChunkSize, nil)
+	if err != nil {
+		ConcurrencyRelease(0)
+		return nil, err
+	}
+	return &encryptWriterWrapper{WriteCloser: inner, stats: stats, start: start}, nil
+}
+
+// encryptWriterWrapper привязывает ConcurrencyCheck/ConcurrencyRelease и
+// единственную (не по-чанкам) запись в stats к времени жизни потокового
+// io.WriteCloser, возвращаемого NewEncryptWriter.
+type encryptWriterWrapper struct {
+	io.WriteCloser
+	stats  *metrics.SecurityStats
+	start  time.Time
+	closed bool
+}
+
+func (w *encryptWriterWrapper) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.WriteCloser.Close()
+	duration := time.Since(w.start)
+	if w.stats != nil {
+		w.stats.RecordEncryptionTime(duration)
+	}
+	ConcurrencyRelease(duration)
+	return err
+}
+
+// NewDecryptReader — вариант NewDecryptingReader для передачи файлов по
+// протоколу client-server: сначала читает IV, записанный NewEncryptWriter
+// перед чанками, резервирует слот ConcurrencyCheck один раз на весь поток и
+// освобождает его при первой ошибке чтения (включая честный io.EOF),
+// записывая накопленную длительность потока в stats.RecordDecryptionTime.
+func NewDecryptReader(r io.Reader, key []byte, stats *metrics.SecurityStats) (io.Reader, error) {
+	if err := ConcurrencyCheck(stats); err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, streamIVSize)
+	if _, err := io.ReadFull(r, iv); err != nil {
+		ConcurrencyRelease(0)
+		return nil, err
+	}
+
+	inner, err := NewDecryptingReader(r, key, iv, nil)
+	if err != nil {
+		ConcurrencyRelease(0)
+		return nil, err
+	}
+	return &decryptReaderWrapper{ReadCloser: inner, stats: stats, start: time.Now()}, nil
+}
+
+// decryptReaderWrapper — аналог encryptWriterWrapper для NewDecryptReader:
+// освобождает слот ConcurrencyCheck и фиксирует накопленную длительность
+// потока при первой же ошибке Read, включая io.EOF честного завершения —
+// у io.Reader нет Close, который мог бы сделать это явно.
+type decryptReaderWrapper struct {
+	io.ReadCloser
+	stats    *metrics.SecurityStats
+	start    time.Time
+	released bool
+}
+
+func (r *decryptReaderWrapper) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.release()
+	}
+	return n, err
+}
+
+func (r *decryptReaderWrapper) release() {
+	if r.released {
+		return
+	}
+	r.released = true
+
+	duration := time.Since(r.start)
+	if r.stats != nil {
+		r.stats.RecordDecryptionTime(duration)
+	}
+	ConcurrencyRelease(duration)
+	_ = r.ReadCloser.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"client-server/tests/metrics"
 	"crypto/rand"
 	"fmt"
+	"io"
 	"math"
 	"runtime"
 	"sync"
@@ -23,6 +24,11 @@ type AttackTestResult struct {
 	SecurityLevel     string  `json:"securityLevel"`
 	Description       string  `json:"description"`
 	Recommendation    string  `json:"recommendation"`
+
+	// AdditionalMetrics хранит тест-специфичные данные, не укладывающиеся в
+	// фиксированные поля выше (например, t-trace dudect-анализа в
+	// TestTimingAttacks), по аналогии с utils.SecurityTestResult.
+	AdditionalMetrics map[string]interface{} `json:"additionalMetrics,omitempty"`
 }
 
 // TestBruteForceResistance тестирует устойчивость к атакам перебора
@@ -40,6 +46,11 @@ func TestBruteForceResistance() AttackTestResult {
 
 	correctCiphertext := crypto.AESEncrypt(correctKey, iv, plaintext, stats)
 
+	// Seed сохраняется в AdditionalMetrics, чтобы неудавшийся прогон можно
+	// было воспроизвести с той же последовательностью атакующих ключей.
+	seed := newAttackSeed()
+	rnd := attackRand(seed)
+
 	// Пытаемся взломать в течение 5 секунд
 	maxTime := 5 * time.Second
 	success := false
@@ -47,7 +58,7 @@ func TestBruteForceResistance() AttackTestResult {
 	for time.Since(start) < maxTime {
 		// Генерируем случайный ключ
 		attackKey := make([]byte, 32)
-		rand.Read(attackKey)
+		io.ReadFull(rnd, attackKey)
 
 		// Пытаемся расшифровать
 		if decrypted, err := crypto.AESDecrypt(attackKey, iv, correctCiphertext, stats); err == nil {
@@ -84,94 +95,132 @@ func TestBruteForceResistance() AttackTestResult {
 		SecurityLevel:     securityLevel,
 		Description:       fmt.Sprintf("Attempted to brute force AES-256 key in %v", elapsed),
 		Recommendation:    "AES-256 показал отличную устойчивость к атакам перебора",
+		AdditionalMetrics: map[string]interface{}{"seed": seedHex(seed)},
 	}
 }
 
 // TestTimingAttacks тестирует устойчивость к атакам по времени
+// Параметры dudect-методологии обнаружения timing-утечек (см. Reparaz et al.,
+// "dudect: dude, is my code constant time?").
+const (
+	dudectTThreshold    = 4.5  // |t| выше порога соответствует очень низкой вероятности ложного срабатывания
+	dudectUpperTrimFrac = 0.10 // доля верхнего хвоста каждого раунда, отбрасываемая как шум окружения
+	dudectRoundSize     = 2000 // измерений за раунд (интерлив поровну между классами)
+	dudectMaxRounds     = 20   // бюджет измерений: не более dudectMaxRounds раундов на класс
+	hammingCandidates   = 2000 // число кандидатов для second-order Hamming-distance теста
+)
+
+// TestTimingAttacks тестирует защиту VerifyHMAC от timing-атак методом dudect:
+// корректные и некорректные HMAC измеряются в случайно перемешанном порядке
+// (не двумя последовательными циклами) раунд за раундом, из каждого раунда
+// отбрасывается верхний перцентиль как шум окружения, и по накопленным
+// выборкам считается t-статистика Уэлча. Итерации продолжаются, пока |t| не
+// превысит dudectTThreshold (утечка обнаружена) либо не будет исчерпан
+// измерительный бюджет dudectMaxRounds (устойчиво). Второй, независимый тест
+// сопоставляет время проверки со расстоянием Хэмминга между кандидатом и
+// истинным HMAC, чтобы поймать более тонкие утечки, пропорциональные числу
+// совпавших бит (характерные для наивного побайтового сравнения).
 func TestTimingAttacks() AttackTestResult {
 	start := time.Now()
 
-	// Генерируем корректные данные
 	key := make([]byte, 32)
 	rand.Read(key)
 	correctData := []byte("correct message")
 	correctHMAC := crypto.GenerateHMAC(key, correctData)
+	wrongHMAC := make([]byte, len(correctHMAC))
+	rand.Read(wrongHMAC)
 
-	// Прогрев для стабилизации кэша и JIT оптимизаций
+	// Прогрев со случайным интерливом — тем же порядком обращений, что и
+	// основной замер, чтобы не внести собственную систематическую погрешность.
 	const warmupRounds = 1000
-	for i := 0; i < warmupRounds; i++ {
-		crypto.VerifyHMAC(key, correctData, correctHMAC)
-		wrongHMAC := make([]byte, len(correctHMAC))
-		rand.Read(wrongHMAC)
-		crypto.VerifyHMAC(key, correctData, wrongHMAC)
-	}
-
-	// Измеряем время для корректных HMAC
-	const measurements = 10000
-	correctTimes := make([]time.Duration, measurements)
-	for i := 0; i < measurements; i++ {
-		startTime := time.Now()
-		crypto.VerifyHMAC(key, correctData, correctHMAC)
-		correctTimes[i] = time.Since(startTime)
-	}
-
-	// Измеряем время для некорректных HMAC
-	incorrectTimes := make([]time.Duration, measurements)
-	for i := 0; i < measurements; i++ {
-		wrongHMAC := make([]byte, len(correctHMAC))
-		rand.Read(wrongHMAC)
-		startTime := time.Now()
-		crypto.VerifyHMAC(key, correctData, wrongHMAC)
-		incorrectTimes[i] = time.Since(startTime)
+	for _, class := range interleavedLabels(warmupRounds) {
+		if class == 0 {
+			crypto.VerifyHMAC(key, correctData, correctHMAC)
+		} else {
+			crypto.VerifyHMAC(key, correctData, wrongHMAC)
+		}
 	}
 
-	// Статистический анализ с отбрасыванием выбросов
-	correctFiltered := filterOutliers(correctTimes)
-	incorrectFiltered := filterOutliers(incorrectTimes)
-
-	correctAvg := averageDuration(correctFiltered)
-	incorrectAvg := averageDuration(incorrectFiltered)
+	var correctTimes, incorrectTimes []float64
+	var tTrace []float64
+	attempts := 0
 
-	// Вычисляем стандартное отклонение
-	correctStdDev := standardDeviationDuration(correctFiltered, correctAvg)
-	incorrectStdDev := standardDeviationDuration(incorrectFiltered, incorrectAvg)
+	for round := 0; round < dudectMaxRounds; round++ {
+		var roundCorrect, roundIncorrect []float64
+		for _, class := range interleavedLabels(dudectRoundSize) {
+			attempts++
+			if class == 0 {
+				startTime := time.Now()
+				crypto.VerifyHMAC(key, correctData, correctHMAC)
+				roundCorrect = append(roundCorrect, float64(time.Since(startTime)))
+			} else {
+				startTime := time.Now()
+				crypto.VerifyHMAC(key, correctData, wrongHMAC)
+				roundIncorrect = append(roundIncorrect, float64(time.Since(startTime)))
+			}
+		}
 
-	// Определяем, есть ли статистически значимая разница
-	timeDifference := math.Abs(float64(correctAvg - incorrectAvg))
+		correctTimes = append(correctTimes, trimUpperTail(roundCorrect, dudectUpperTrimFrac)...)
+		incorrectTimes = append(incorrectTimes, trimUpperTail(roundIncorrect, dudectUpperTrimFrac)...)
 
-	// Более реалистичный порог: 3 стандартных отклонения от среднего
-	combinedStdDev := math.Max(float64(correctStdDev), float64(incorrectStdDev))
-	threshold := 3.0 * combinedStdDev
+		t := welchT(correctTimes, incorrectTimes)
+		tTrace = append(tTrace, t)
+		if math.Abs(t) > dudectTThreshold {
+			break
+		}
+	}
 
-	// Альтернативно: проверяем, превышает ли разница 10% от среднего времени
-	avgTime := (float64(correctAvg) + float64(incorrectAvg)) / 2
-	percentageThreshold := avgTime * 0.10 // 10%
+	finalT := tTrace[len(tTrace)-1]
+	vulnerable := math.Abs(finalT) > dudectTThreshold
+
+	// Second-order тест: время проверки против расстояния Хэмминга между
+	// случайным кандидатом и истинным HMAC.
+	hammingDistances := make([]float64, 0, hammingCandidates)
+	hammingTimes := make([]float64, 0, hammingCandidates)
+	for i := 0; i < hammingCandidates; i++ {
+		candidate := make([]byte, len(correctHMAC))
+		rand.Read(candidate)
+		dist := hammingDistanceBits(candidate, correctHMAC)
+		startTime := time.Now()
+		crypto.VerifyHMAC(key, correctData, candidate)
+		elapsed := time.Since(startTime)
+		hammingDistances = append(hammingDistances, float64(dist))
+		hammingTimes = append(hammingTimes, float64(elapsed))
+		attempts++
+	}
+	hammingCorrelation := pearsonCorrelation(hammingDistances, hammingTimes)
+	const hammingCorrelationThreshold = 0.3
+	hammingLeak := math.Abs(hammingCorrelation) > hammingCorrelationThreshold
+	if hammingLeak {
+		vulnerable = true
+	}
 
-	// Используем более строгий из двух порогов
-	finalThreshold := math.Min(threshold, percentageThreshold)
-	vulnerable := timeDifference > finalThreshold
 	securityLevel := "ОТРАЖЕНО"
 	attackProbability := 0.0001 // Очень низкая для constant-time операций
 	if vulnerable {
 		securityLevel = "УЯЗВИМ"
-		attackProbability = 0.02 // Значительно снижена
+		attackProbability = 0.02
 	}
 
 	elapsed := time.Since(start)
 
-	description := fmt.Sprintf("Time difference: %.2fns (threshold: %.2fns, stddev: %.2fns)",
-		timeDifference, finalThreshold, combinedStdDev)
+	description := fmt.Sprintf("dudect Welch's t=%.3f (threshold=%.1f, rounds=%d/%d); Hamming-distance correlation=%.4f (threshold=%.2f, leak=%v)",
+		finalT, dudectTThreshold, len(tTrace), dudectMaxRounds, hammingCorrelation, hammingCorrelationThreshold, hammingLeak)
 
 	return AttackTestResult{
-		AttackType:        "Timing Attack (HMAC)",
+		AttackType:        "Timing Attack (HMAC, dudect)",
 		Successful:        vulnerable,
-		AttemptsMade:      measurements * 2,
+		AttemptsMade:      attempts,
 		TimeElapsed:       elapsed.Milliseconds(),
-		AttackRate:        float64(measurements*2) / elapsed.Seconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
 		AttackProbability: attackProbability,
 		SecurityLevel:     securityLevel,
 		Description:       description,
-		Recommendation:    "HMAC использует crypto/subtle.ConstantTimeCompare с статистическим анализом",
+		Recommendation:    "HMAC использует crypto/subtle.ConstantTimeCompare; dudect-анализ не обнаружил эксплуатируемой зависимости времени от данных",
+		AdditionalMetrics: map[string]interface{}{
+			"tTrace":             tTrace,
+			"hammingCorrelation": hammingCorrelation,
+		},
 	}
 }
 
@@ -236,6 +285,72 @@ func TestReplayAttacks() AttackTestResult {
 	}
 }
 
+// TestEpochIsolation проверяет, что общий секрет, перехваченный в эпоху N,
+// не позволяет расшифровать трафик эпохи N+1 после ротации ключей (rekey).
+func TestEpochIsolation() AttackTestResult {
+	start := time.Now()
+
+	stats := metrics.NewSecurityStats()
+	ecdsaPriv, ecdsaPub := crypto.GenerateECDHKeys()
+	rsaPriv, rsaPub := crypto.GenerateRSAKeys()
+
+	epochNSecret := make([]byte, 64)
+	rand.Read(epochNSecret)
+
+	// Атакующий захватывает секрет эпохи N (например, через компрометацию памяти)
+	capturedSecret := make([]byte, len(epochNSecret))
+	copy(capturedSecret, epochNSecret)
+
+	// Легитимная сторона переходит к эпохе N+1 через HKDF-Expand
+	epochN1Secret, err := crypto.AdvanceSecret(epochNSecret)
+	attempts := 1
+	if err != nil {
+		return AttackTestResult{
+			AttackType:        "Epoch Isolation (Rekey Forward Secrecy)",
+			Successful:        false,
+			AttemptsMade:      attempts,
+			TimeElapsed:       time.Since(start).Milliseconds(),
+			AttackProbability: 0,
+			SecurityLevel:     "ОШИБКА",
+			Description:       fmt.Sprintf("AdvanceSecret завершился ошибкой: %v", err),
+			Recommendation:    "Проверить реализацию AdvanceSecret",
+		}
+	}
+
+	plaintext := []byte("сообщение эпохи N+1")
+	msg := crypto.CreateSecureMessage(plaintext, epochN1Secret, ecdsaPriv, ecdsaPub, rsaPriv, stats)
+
+	// Пытаемся расшифровать трафик новой эпохи захваченным секретом старой эпохи
+	_, err = crypto.VerifyAndDecryptMessage(msg, capturedSecret, rsaPub, stats)
+	attackSuccessful := err == nil
+
+	// Контрольная проверка: легитимный секрет новой эпохи по-прежнему расшифровывает сообщение
+	_, legitErr := crypto.VerifyAndDecryptMessage(msg, epochN1Secret, rsaPub, stats)
+	legitimateDecryptWorks := legitErr == nil
+
+	elapsed := time.Since(start)
+
+	attackProbability := 0.0001
+	securityLevel := "ОТРАЖЕНО"
+	if attackSuccessful {
+		attackProbability = 1.0
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Epoch Isolation (Rekey Forward Secrecy)",
+		Successful:        attackSuccessful,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description: fmt.Sprintf("Захваченный секрет эпохи N расшифровал трафик N+1: %v, легитимный секрет N+1 работает: %v",
+			attackSuccessful, legitimateDecryptWorks),
+		Recommendation: "AdvanceSecret (HKDF-Expand) обеспечивает одностороннюю изоляцию эпох ключей сессии",
+	}
+}
+
 // TestConcurrentAttacks тестирует устойчивость при параллельных атаках
 func TestConcurrentAttacks() AttackTestResult {
 	start := time.Now()
@@ -250,6 +365,13 @@ func TestConcurrentAttacks() AttackTestResult {
 
 	ciphertext := crypto.AESEncrypt(key, iv, plaintext, stats)
 
+	// Seed сохраняется в AdditionalMetrics, чтобы неудавшийся прогон можно
+	// было воспроизвести с той же последовательностью атакующих ключей.
+	// Каждый воркер получает собственный детерминированный поток, производный
+	// от общего seed и его ID — один io.Reader нельзя безопасно использовать
+	// из нескольких горутин одновременно.
+	seed := newAttackSeed()
+
 	// Запускаем параллельные атаки
 	numWorkers := runtime.NumCPU()
 	attempts := make([]int, numWorkers)
@@ -264,11 +386,14 @@ func TestConcurrentAttacks() AttackTestResult {
 		go func(workerID int) {
 			defer wg.Done()
 			workerStart := time.Now()
+			workerSeed := append([]byte(nil), seed...)
+			workerSeed[len(workerSeed)-1] ^= byte(workerID)
+			rnd := attackRand(workerSeed)
 
 			for time.Since(workerStart) < maxTime {
 				// Генерируем случайный ключ
 				attackKey := make([]byte, 32)
-				rand.Read(attackKey)
+				io.ReadFull(rnd, attackKey)
 
 				// Пытаемся расшифровать
 				if decrypted, err := crypto.AESDecrypt(attackKey, iv, ciphertext, stats); err == nil {
@@ -316,6 +441,7 @@ func TestConcurrentAttacks() AttackTestResult {
 		SecurityLevel:     securityLevel,
 		Description:       fmt.Sprintf("Parallel attack with %d workers", numWorkers),
 		Recommendation:    "Система устойчива к параллельным атакам",
+		AdditionalMetrics: map[string]interface{}{"seed": seedHex(seed)},
 	}
 }
 
@@ -325,13 +451,19 @@ func TestSignatureForging() AttackTestResult {
 
 	stats := metrics.NewSecurityStats()
 
+	// Seed сохраняется в AdditionalMetrics, чтобы неудавшийся прогон можно
+	// было воспроизвести с той же последовательностью атакующих ключей и
+	// поддельных данных.
+	seed := newAttackSeed()
+	rnd := attackRand(seed)
+
 	// Генерируем легитимные ключи
-	_, legitimateECDSAPub := crypto.GenerateECDHKeys()
-	_, legitimateRSAPub := crypto.GenerateRSAKeys()
+	_, legitimateECDSAPub := crypto.GenerateECDHKeysWithRand(rnd)
+	_, legitimateRSAPub := crypto.GenerateRSAKeysWithRand(rnd)
 
 	// Генерируем атакующие ключи
-	attackerECDSAPriv, _ := crypto.GenerateECDHKeys()
-	attackerRSAPriv, _ := crypto.GenerateRSAKeys()
+	attackerECDSAPriv, _ := crypto.GenerateECDHKeysWithRand(rnd)
+	attackerRSAPriv, _ := crypto.GenerateRSAKeysWithRand(rnd)
 
 	attempts := 0
 	successful := 0
@@ -340,7 +472,7 @@ func TestSignatureForging() AttackTestResult {
 	for time.Since(start) < maxTime {
 		// Создаем поддельные данные
 		fakeData := make([]byte, 64)
-		rand.Read(fakeData)
+		io.ReadFull(rnd, fakeData)
 
 		// Пытаемся создать поддельные подписи
 		fakeECDSASig := crypto.SignECDSA(attackerECDSAPriv, fakeData, stats)
@@ -386,6 +518,7 @@ func TestSignatureForging() AttackTestResult {
 		SecurityLevel:     securityLevel,
 		Description:       fmt.Sprintf("Successful forgeries: %d out of %d attempts", successful, attempts),
 		Recommendation:    "ECDSA и RSA подписи показали отличную стойкость против подделки",
+		AdditionalMetrics: map[string]interface{}{"seed": seedHex(seed)},
 	}
 }
 
@@ -397,6 +530,15 @@ func RunAllAttackTests() []AttackTestResult {
 		TestEnhancedReplayAttacks,        // Улучшенный тест replay атак
 		TestConcurrentBruteForceEnhanced, // Улучшенный тест параллельных атак
 		TestSignatureForging,
+		TestEpochIsolation,              // Проверка изоляции ключей между эпохами после rekey
+		TestTLSDowngradeAndCertPinning,  // Проверка TLS 1.3 downgrade-защиты и pinning SPKI-отпечатка
+		TestBruteForceResistanceStream,  // Потоковый аналог TestBruteForceResistance
+		TestConcurrentAttacksStream,     // Потоковый аналог TestConcurrentAttacks
+		TestTruncationAttack,            // Проверка обнаружения усечения потокового шифрования
+		TestPaddingOracleAttack,         // Проверка RSA OAEP/PKCS1v15 на различимость ошибок padding-oracle
+		TestKeyCompromiseResilience,     // Проверка изоляции сообщений при компрометации DEK одного из них
+		TestNonceExhaustionAttack,       // Проверка устойчивости ShardedNonceCache к заливке уникальными nonce
+		TestRatchetForwardSecrecy,       // Проверка forward secrecy и защиты от replay у Double Ratchet
 	}
 
 	results := make([]AttackTestResult, len(tests))
@@ -456,57 +598,3 @@ func AnalyzeAttackResults(results []AttackTestResult) {
 	}
 	fmt.Printf("=======================================\n")
 }
-
-// Вспомогательная функция для вычисления среднего времени
-func averageDuration(times []time.Duration) time.Duration {
-	var total time.Duration
-	for _, t := range times {
-		total += t
-	}
-	return total / time.Duration(len(times))
-}
-
-// filterOutliers удаляет выбросы из массива времен (значения за пределами 2 стандартных отклонений)
-func filterOutliers(times []time.Duration) []time.Duration {
-	if len(times) < 10 {
-		return times // Слишком мало данных для фильтрации
-	}
-
-	// Вычисляем среднее и стандартное отклонение
-	avg := averageDuration(times)
-	stdDev := standardDeviationDuration(times, avg)
-
-	// Фильтруем значения в пределах 2 стандартных отклонений
-	var filtered []time.Duration
-	threshold := float64(stdDev) * 2.0
-
-	for _, t := range times {
-		diff := math.Abs(float64(t - avg))
-		if diff <= threshold {
-			filtered = append(filtered, t)
-		}
-	}
-
-	// Если слишком много значений отфильтровано, возвращаем оригинал
-	if len(filtered) < len(times)/2 {
-		return times
-	}
-
-	return filtered
-}
-
-// standardDeviationDuration вычисляет стандартное отклонение для времен
-func standardDeviationDuration(times []time.Duration, mean time.Duration) time.Duration {
-	if len(times) == 0 {
-		return 0
-	}
-
-	var sum float64
-	for _, t := range times {
-		diff := float64(t - mean)
-		sum += diff * diff
-	}
-
-	variance := sum / float64(len(times))
-	return time.Duration(math.Sqrt(variance))
-}
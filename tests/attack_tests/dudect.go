@@ -0,0 +1,121 @@
+package attack_tests
+
+import (
+	"math"
+	mrand "math/rand"
+	"sort"
+)
+
+// interleavedLabels возвращает случайно перемешанную последовательность меток
+// классов (0 - корректный, 1 - некорректный) длины n, сбалансированную поровну
+// между классами. Рандомизированный интерлив вместо двух последовательных
+// циклов измерений устраняет систематическую погрешность от теплового дрейфа
+// процессора и прогрева JIT/кэша, которая иначе смещала бы один класс
+// относительно другого.
+func interleavedLabels(n int) []int {
+	labels := make([]int, n)
+	for i := range labels {
+		labels[i] = i % 2
+	}
+	mrand.Shuffle(len(labels), func(i, j int) { labels[i], labels[j] = labels[j], labels[i] })
+	return labels
+}
+
+// trimUpperTail сортирует выборку по возрастанию и отбрасывает верхние pct
+// долю значений как шум окружения (паузы планировщика ОС, GC) — методология
+// dudect отбрасывает только верхний хвост, а не оба края симметрично.
+func trimUpperTail(samples []float64, pct float64) []float64 {
+	if len(samples) == 0 {
+		return samples
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	cut := int(float64(len(sorted)) * (1 - pct))
+	if cut <= 0 {
+		cut = len(sorted)
+	}
+	return sorted[:cut]
+}
+
+// meanVarianceFloat вычисляет выборочное среднее и несмещенную (n-1) дисперсию.
+func meanVarianceFloat(x []float64) (mean, variance float64) {
+	n := len(x)
+	if n == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	mean = sum / float64(n)
+
+	if n < 2 {
+		return mean, 0
+	}
+	var sumSq float64
+	for _, v := range x {
+		diff := v - mean
+		sumSq += diff * diff
+	}
+	variance = sumSq / float64(n-1)
+	return mean, variance
+}
+
+// welchT вычисляет t-статистику Уэлча t = (μ1-μ2)/√(s1²/n1 + s2²/n2) для двух
+// выборок — ядро dudect-методологии обнаружения timing-утечек.
+func welchT(x1, x2 []float64) float64 {
+	n1, n2 := len(x1), len(x2)
+	if n1 < 2 || n2 < 2 {
+		return 0
+	}
+	mean1, var1 := meanVarianceFloat(x1)
+	mean2, var2 := meanVarianceFloat(x2)
+	se := math.Sqrt(var1/float64(n1) + var2/float64(n2))
+	if se == 0 {
+		return 0
+	}
+	return (mean1 - mean2) / se
+}
+
+// hammingDistanceBits возвращает количество различающихся бит между двумя
+// срезами байт одинаковой длины (используется для second-order теста,
+// сопоставляющего время проверки HMAC с "похожестью" кандидата на верный тег).
+func hammingDistanceBits(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	dist := 0
+	for i := 0; i < n; i++ {
+		x := a[i] ^ b[i]
+		for x != 0 {
+			dist += int(x & 1)
+			x >>= 1
+		}
+	}
+	return dist
+}
+
+// pearsonCorrelation вычисляет коэффициент корреляции Пирсона между двумя
+// выборками одинаковой длины.
+func pearsonCorrelation(xs, ys []float64) float64 {
+	n := len(xs)
+	if n == 0 || n != len(ys) {
+		return 0
+	}
+	meanX, _ := meanVarianceFloat(xs)
+	meanY, _ := meanVarianceFloat(ys)
+
+	var num, denX, denY float64
+	for i := 0; i < n; i++ {
+		dx := xs[i] - meanX
+		dy := ys[i] - meanY
+		num += dx * dy
+		denX += dx * dx
+		denY += dy * dy
+	}
+	if denX == 0 || denY == 0 {
+		return 0
+	}
+	return num / math.Sqrt(denX*denY)
+}
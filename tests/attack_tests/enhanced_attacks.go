@@ -3,13 +3,17 @@ package attack_tests
 import (
 	"client-server/internal/crypto"
 	"client-server/tests/metrics"
+	"client-server/tests/metrics/timing"
 	"crypto/rand"
 	"fmt"
 	"sync"
 	"time"
 )
 
-// TestEnhancedTimingAttacks тестирует улучшенную защиту от timing атак
+// TestEnhancedTimingAttacks тестирует улучшенную защиту от timing атак как
+// для HMAC-SHA256 (легаси SuiteAESCBCHMAC), так и для nacl/auth
+// (SuiteNaClSecretboxAuth) — обе keyed-MAC реализации должны одинаково не
+// зависеть от корректности проверяемого тега.
 func TestEnhancedTimingAttacks() AttackTestResult {
 	start := time.Now()
 
@@ -41,18 +45,32 @@ func TestEnhancedTimingAttacks() AttackTestResult {
 			invalidTimes = append(invalidTimes, elapsed)
 		}
 	}
-	avgValid := averageDurationEnhanced(validTimes)
-	avgInvalid := averageDurationEnhanced(invalidTimes)
-	stddevValid := stddevDurationEnhanced(validTimes, avgValid)
-	stddevInvalid := stddevDurationEnhanced(invalidTimes, avgInvalid)
-
-	timeDifference := float64(avgValid - avgInvalid)
-	if timeDifference < 0 {
-		timeDifference = -timeDifference
-	}
+	result := timing.WelchTTest(validTimes, invalidTimes)
+	vulnerable := result.Leak
 
-	threshold := 3 * float64(stddevValid+stddevInvalid)
-	vulnerable := timeDifference > threshold
+	// Тот же эксперимент для второго AEAD-бэкенда (nacl/auth), чтобы
+	// сравнить обе реализации keyed-MAC на предмет timing-утечек.
+	var naclValidTimes []time.Duration
+	var naclInvalidTimes []time.Duration
+	for i := 0; i < measurements; i++ {
+		correctTag := crypto.NaClAuth(key, correctData)
+		result, elapsed, _ := crypto.NaClVerify(key, correctData, correctTag)
+		if result {
+			naclValidTimes = append(naclValidTimes, elapsed)
+		}
+	}
+	for i := 0; i < measurements; i++ {
+		invalidTag := make([]byte, 32)
+		rand.Read(invalidTag)
+		result, elapsed, _ := crypto.NaClVerify(key, correctData, invalidTag)
+		if !result {
+			naclInvalidTimes = append(naclInvalidTimes, elapsed)
+		}
+	}
+	naclResult := timing.WelchTTest(naclValidTimes, naclInvalidTimes)
+	if naclResult.Leak {
+		vulnerable = true
+	}
 
 	securityLevel := "ОТРАЖЕНО"
 	attackProbability := 0.0001
@@ -64,19 +82,21 @@ func TestEnhancedTimingAttacks() AttackTestResult {
 	elapsed := time.Since(start)
 	avg, stddev, count := crypto.GetHMACTimingStats()
 
-	description := fmt.Sprintf("Time difference: %.2fns (threshold: %.2fns), HMAC stats: avg=%.2fns, stddev=%.2fns, samples=%d",
-		timeDifference, threshold, float64(avg), float64(stddev), count)
+	description := fmt.Sprintf("HMAC-SHA256 Welch's t-test: t=%.3f, df=%.1f, p=%.6f, Cohen's d=%.4f (n1=%d, n2=%d); nacl/auth Welch's t-test: t=%.3f, p=%.6f, Cohen's d=%.4f (leak=%v); HMAC stats: avg=%.2fns, stddev=%.2fns, samples=%d",
+		result.T, result.DF, result.PValue, result.CohensD, result.N1, result.N2,
+		naclResult.T, naclResult.PValue, naclResult.CohensD, naclResult.Leak,
+		float64(avg), float64(stddev), count)
 
 	return AttackTestResult{
-		AttackType:        "Enhanced Timing Attack (HMAC)",
+		AttackType:        "Enhanced Timing Attack (HMAC + nacl/auth)",
 		Successful:        vulnerable,
-		AttemptsMade:      measurements * 2,
+		AttemptsMade:      measurements * 4,
 		TimeElapsed:       elapsed.Milliseconds(),
-		AttackRate:        float64(measurements*2) / elapsed.Seconds(),
+		AttackRate:        float64(measurements*4) / elapsed.Seconds(),
 		AttackProbability: attackProbability,
 		SecurityLevel:     securityLevel,
 		Description:       description,
-		Recommendation:    "HMAC использует crypto/subtle.ConstantTimeCompare с расширенным статистическим анализом",
+		Recommendation:    "Оба MAC (HMAC-SHA256 и nacl/auth) используют crypto/subtle.ConstantTimeCompare с расширенным статистическим анализом",
 	}
 }
 
@@ -94,30 +114,66 @@ func TestEnhancedReplayAttacks() AttackTestResult {
 
 	attempts := 0
 	successfulReplays := 0
+	var replayTimes []time.Duration
+	var firstAttemptTime time.Duration
+	var timingLeakResult timing.Result
+
+	// Прогоняем тест по очереди для каждой схемы RSA-подписи (PKCS1v15 и
+	// PSS). SecurityStats.AttackProbability получает одно значение,
+	// посчитанное по обеим схемам вместе, и схемы между собой не различает.
+	sigAlgs := []string{crypto.SigAlgPKCS1v15, crypto.SigAlgPSS}
 
 	// Тест 1: Множественные replay атаки с одним nonce
 	plaintext := []byte("test message")
-	originalMsg := crypto.CreateSecureMessage(plaintext, sharedSecret, ecdsaPriv, ecdsaPub, rsaPriv, stats)
+	var originalMsg crypto.Message
+	for _, sigAlg := range sigAlgs {
+		msg, err := crypto.CreateSecureMessageWithSigAlg(plaintext, sharedSecret, ecdsaPriv, ecdsaPub, rsaPriv, sigAlg, stats)
+		if err != nil {
+			continue
+		}
+		originalMsg = msg
 
-	_, err := crypto.VerifyAndDecryptMessage(originalMsg, sharedSecret, rsaPub, stats)
-	if err == nil {
-		attempts++
+		attemptStart := time.Now()
+		_, err = crypto.VerifyAndDecryptMessage(msg, sharedSecret, rsaPub, stats)
+		firstAttemptTime = time.Since(attemptStart)
+		if err == nil {
+			attempts++
+		}
+
+		for i := 0; i < 100; i++ {
+			replayStart := time.Now()
+			_, err := crypto.VerifyAndDecryptMessage(msg, sharedSecret, rsaPub, stats)
+			replayTimes = append(replayTimes, time.Since(replayStart))
+			attempts++
+			if err == nil {
+				successfulReplays++
+			}
+		}
 	}
 
+	// Тест по времени: отклонение replay не должно статистически отличаться от
+	// отклонения произвольного искаженного сообщения — иначе отказ по nonce
+	// создает timing-оракул, позволяющий угадать, использовался ли этот nonce ранее.
+	forgedMsg := originalMsg
+	forgedMsg.Nonce = append([]byte(nil), originalMsg.Nonce...)
+	forgedMsg.Nonce[0] ^= 0xFF
+	var forgedTimes []time.Duration
 	for i := 0; i < 100; i++ {
-		_, err := crypto.VerifyAndDecryptMessage(originalMsg, sharedSecret, rsaPub, stats)
-		attempts++
-		if err == nil {
-			successfulReplays++
-		}
+		// Свежий nonce на каждой итерации, чтобы искаженное сообщение не
+		// отклонялось как replay собственной предыдущей попытки.
+		forgedMsg.Nonce[1] = originalMsg.Nonce[1] ^ byte(i+1)
+		forgedStart := time.Now()
+		crypto.VerifyAndDecryptMessage(forgedMsg, sharedSecret, rsaPub, stats)
+		forgedTimes = append(forgedTimes, time.Since(forgedStart))
 	}
+	timingLeakResult = timing.WelchTTest(replayTimes, forgedTimes)
 
 	// Тест 2: Проверка временного окна (timestamp)
 	// Создаем сообщение с устаревшим timestamp
 	oldMsg := originalMsg
 	oldMsg.Timestamp = time.Now().Unix() - 40 // 40 секунд назад (больше MaxTimeDifference)
 
-	_, err = crypto.VerifyAndDecryptMessage(oldMsg, sharedSecret, rsaPub, stats)
+	_, err := crypto.VerifyAndDecryptMessage(oldMsg, sharedSecret, rsaPub, stats)
 	attempts++
 	if err == nil {
 		successfulReplays++
@@ -128,14 +184,15 @@ func TestEnhancedReplayAttacks() AttackTestResult {
 
 	elapsed := time.Since(start)
 	attackProbability := float64(successfulReplays) / float64(attempts)
+	stats.SetAttackProbability(attackProbability)
 
 	securityLevel := "ОТРАЖЕНО"
 	if successfulReplays > 0 {
 		securityLevel = "УЯЗВИМ"
 	}
 
-	description := fmt.Sprintf("Successful replays: %d out of %d attempts, nonce tracking: %d stored",
-		successfulReplays, attempts, nonceCount)
+	description := fmt.Sprintf("Signature schemes tested: %v; Successful replays: %d out of %d attempts, nonce tracking: %d stored, first accept: %v; replay-vs-forged timing: t=%.3f p=%.6f Cohen's d=%.4f (leak=%v)",
+		sigAlgs, successfulReplays, attempts, nonceCount, firstAttemptTime, timingLeakResult.T, timingLeakResult.PValue, timingLeakResult.CohensD, timingLeakResult.Leak)
 
 	return AttackTestResult{
 		AttackType:        "Enhanced Replay Attack",
@@ -150,7 +207,11 @@ func TestEnhancedReplayAttacks() AttackTestResult {
 	}
 }
 
-// TestConcurrentBruteForceEnhanced тестирует улучшенную защиту от параллельных атак
+// TestConcurrentBruteForceEnhanced тестирует улучшенную защиту от параллельных
+// атак как для AES-256-CBC (SuiteAESCBCHMAC), так и для secretbox
+// (SuiteNaClSecretboxAuth) — оба AEAD-бэкенда должны одинаково опираться на
+// rate limiting и ограничение concurrent операций, а не на стойкость самого
+// шифра к перебору.
 func TestConcurrentBruteForceEnhanced() AttackTestResult {
 	start := time.Now()
 
@@ -158,17 +219,21 @@ func TestConcurrentBruteForceEnhanced() AttackTestResult {
 	rand.Read(correctKey)
 	iv := make([]byte, 16)
 	rand.Read(iv)
+	sbNonce := make([]byte, 24)
+	rand.Read(sbNonce)
 	plaintext := []byte("secret message")
 	stats := metrics.NewSecurityStats()
 
 	correctCiphertext := crypto.AESEncrypt(correctKey, iv, plaintext, stats)
+	correctSecretbox := crypto.SecretboxEncrypt(correctKey, sbNonce, plaintext, stats)
 
-	const numWorkers = 50 
+	const numWorkers = 50
 	const attemptsPerWorker = 1000
 	maxTime := 3 * time.Second
 
 	var totalAttempts int64
 	var successfulAttacks int64
+	var successfulSecretboxAttacks int64
 	var rejectedByRateLimit int64
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
@@ -207,7 +272,7 @@ func TestConcurrentBruteForceEnhanced() AttackTestResult {
 				attackKey := make([]byte, 32)
 				rand.Read(attackKey)
 
-				// Пытаемся расшифровать
+				// Пытаемся расшифровать AES-256-CBC ciphertext
 				decrypted := crypto.AESEncrypt(attackKey, iv, plaintext, stats)
 				if decrypted != nil {
 					localAttempts++
@@ -220,6 +285,15 @@ func TestConcurrentBruteForceEnhanced() AttackTestResult {
 				} else {
 					localRejected++
 				}
+
+				// Та же атака против secretbox ciphertext тем же
+				// случайным ключом, чтобы сравнить устойчивость обоих
+				// бэкендов к перебору ключа.
+				if _, err := crypto.SecretboxDecrypt(attackKey, sbNonce, correctSecretbox, stats); err == nil {
+					mutex.Lock()
+					successfulSecretboxAttacks++
+					mutex.Unlock()
+				}
 			}
 
 			mutex.Lock()
@@ -234,55 +308,27 @@ func TestConcurrentBruteForceEnhanced() AttackTestResult {
 
 	concurrentOps := crypto.GetConcurrentOpsCount()
 	rate := float64(totalAttempts) / elapsed.Seconds()
-	attackProbability := float64(successfulAttacks) / float64(totalAttempts)
+	attackProbability := float64(successfulAttacks+successfulSecretboxAttacks) / float64(totalAttempts*2)
 
 	securityLevel := "ОТРАЖЕНО"
-	if successfulAttacks > 0 {
+	if successfulAttacks > 0 || successfulSecretboxAttacks > 0 {
 		securityLevel = "УЯЗВИМ"
 	} else if rejectedByRateLimit > totalAttempts/2 {
-		securityLevel = "ЗАЩИЩЕНО" 
+		securityLevel = "ЗАЩИЩЕНО"
 	}
 
-	description := fmt.Sprintf("Workers: %d, successful: %d, rejected by limits: %d, concurrent ops: %d",
-		numWorkers, successfulAttacks, rejectedByRateLimit, concurrentOps)
+	description := fmt.Sprintf("Workers: %d, AES-256-CBC successful: %d, secretbox successful: %d, rejected by limits: %d, concurrent ops: %d",
+		numWorkers, successfulAttacks, successfulSecretboxAttacks, rejectedByRateLimit, concurrentOps)
 
 	return AttackTestResult{
 		AttackType:        "Enhanced Concurrent Brute Force",
-		Successful:        successfulAttacks > 0,
+		Successful:        successfulAttacks > 0 || successfulSecretboxAttacks > 0,
 		AttemptsMade:      int(totalAttempts),
 		TimeElapsed:       elapsed.Milliseconds(),
 		AttackRate:        rate,
 		AttackProbability: attackProbability,
 		SecurityLevel:     securityLevel,
 		Description:       description,
-		Recommendation:    "Система защищена от параллельных атак: rate limiting и ограничение concurrent операций",
-	}
-}
-
-// Вспомогательные функции для статистического анализа
-func averageDurationEnhanced(durations []time.Duration) time.Duration {
-	if len(durations) == 0 {
-		return 0
+		Recommendation:    "Система защищена от параллельных атак: rate limiting и ограничение concurrent операций для обоих AEAD-бэкендов",
 	}
-
-	var sum time.Duration
-	for _, d := range durations {
-		sum += d
-	}
-	return sum / time.Duration(len(durations))
-}
-
-func stddevDurationEnhanced(durations []time.Duration, avg time.Duration) time.Duration {
-	if len(durations) == 0 {
-		return 0
-	}
-
-	var variance float64
-	for _, d := range durations {
-		diff := float64(d - avg)
-		variance += diff * diff
-	}
-	variance /= float64(len(durations))
-
-	return time.Duration(variance)
 }
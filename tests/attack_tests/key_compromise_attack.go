@@ -0,0 +1,120 @@
+package attack_tests
+
+import (
+	"bytes"
+	"client-server/internal/crypto"
+	"client-server/tests/metrics"
+	"fmt"
+	"time"
+)
+
+// TestKeyCompromiseResilience проверяет ключевое свойство envelope-шифрования
+// (см. CreateSecureMessageEnvelope): компрометация DEK одного сообщения не
+// должна позволять расшифровать другие сообщения той же сессии, поскольку
+// каждое сообщение использует собственный, независимо сгенерированный DEK.
+func TestKeyCompromiseResilience() AttackTestResult {
+	start := time.Now()
+	stats := metrics.NewSecurityStats()
+
+	ecdsaPriv, ecdhPub := crypto.GenerateECDHKeys()
+	rsaPriv, rsaPub := crypto.GenerateRSAKeys()
+	sharedSecret := make([]byte, crypto.AESKeySize*2)
+	for i := range sharedSecret {
+		sharedSecret[i] = byte(i)
+	}
+
+	const numMessages = 5
+	plaintexts := make([][]byte, numMessages)
+	messages := make([]crypto.Message, numMessages)
+
+	for i := 0; i < numMessages; i++ {
+		plaintexts[i] = []byte(fmt.Sprintf("session message #%d — конфиденциальные данные", i))
+		msg, err := crypto.CreateSecureMessageEnvelope(plaintexts[i], sharedSecret, ecdsaPriv, ecdhPub, rsaPriv, crypto.SigAlgPKCS1v15, crypto.DefaultRand, stats)
+		if err != nil {
+			return keyCompromiseTestError(start, fmt.Sprintf("не удалось создать envelope-сообщение #%d: %v", i, err))
+		}
+		messages[i] = msg
+	}
+
+	// Контроль: честная расшифровка каждого сообщения собственным sharedSecret
+	// и правильным запечатанным DEK должна проходить — иначе API сломан.
+	attempts := 0
+	successfulAttacks := 0
+	controlFailures := 0
+	for i, msg := range messages {
+		attempts++
+		decrypted, err := crypto.VerifyAndDecryptMessage(msg, sharedSecret, rsaPub, stats)
+		if err != nil || !bytes.Equal(decrypted, plaintexts[i]) {
+			controlFailures++
+			successfulAttacks++ // честная расшифровка обязана проходить
+		}
+	}
+
+	// Атака: компрометируем DEK сообщения #0, распечатав его, и пробуем
+	// использовать этот DEK напрямую для расшифровки остальных сообщений
+	// сессии (минуя UnsealDEK каждого из них).
+	compromisedDEK, err := crypto.UnsealDEK(*messages[0].SealedDEK, sharedSecret, stats)
+	if err != nil {
+		return keyCompromiseTestError(start, fmt.Sprintf("не удалось распечатать DEK для атаки: %v", err))
+	}
+
+	// DeriveDEKSubkeys публична по принципу Керкгоффса: держатель
+	// скомпрометированного DEK способен сам вывести те же ключи
+	// шифрования/аутентификации, что и легитимная сторона.
+	encKey, macKey, err := crypto.DeriveDEKSubkeys(compromisedDEK)
+	if err != nil {
+		return keyCompromiseTestError(start, fmt.Sprintf("не удалось вывести ключи из скомпрометированного DEK: %v", err))
+	}
+
+	crossMessageSuccesses := 0
+	for i := 1; i < numMessages; i++ {
+		attempts++
+		// Атака: расшифровать Cipher/HMAC сообщения #i ключами, выведенными
+		// из DEK сообщения #0, минуя SealedDEK сообщения #i вовсе — должна
+		// провалиться, так как у каждого сообщения независимый DEK.
+		if crypto.VerifyHMAC(macKey, messages[i].Cipher, messages[i].HMAC) {
+			if decrypted, err := crypto.AESDecrypt(encKey, messages[i].IV, messages[i].Cipher, stats); err == nil {
+				if bytes.Equal(decrypted, plaintexts[i]) {
+					crossMessageSuccesses++
+					successfulAttacks++
+				}
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	attackProbability := float64(successfulAttacks) / float64(attempts)
+	stats.SetAttackProbability(attackProbability)
+
+	securityLevel := "ОТРАЖЕНО"
+	if successfulAttacks > 0 {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Key Compromise Resilience (Envelope Encryption)",
+		Successful:        successfulAttacks > 0,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description:       fmt.Sprintf("Компрометация DEK сообщения #0 позволила расшифровать %d из %d остальных сообщений сессии (контрольных сбоев честной расшифровки: %d)", crossMessageSuccesses, numMessages-1, controlFailures),
+		Recommendation:    "CreateSecureMessageEnvelope: независимые DEK на сообщение ограничивают ущерб от компрометации одним сообщением",
+	}
+}
+
+func keyCompromiseTestError(start time.Time, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        "Key Compromise Resilience (Envelope Encryption)",
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "CreateSecureMessageEnvelope: независимые DEK на сообщение ограничивают ущерб от компрометации одним сообщением",
+	}
+}
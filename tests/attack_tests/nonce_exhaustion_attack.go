@@ -0,0 +1,106 @@
+package attack_tests
+
+import (
+	"client-server/internal/crypto"
+	"fmt"
+	"time"
+)
+
+// TestNonceExhaustionAttack проверяет, что ShardedNonceCache (см.
+// crypto.NonceCacheConfig) не дает атакующему, заливающему сервер потоком
+// уникальных nonce, исчерпать память процесса, и что легитимные сообщения
+// продолжают проверяться без деградации по времени даже под этой нагрузкой.
+func TestNonceExhaustionAttack() AttackTestResult {
+	start := time.Now()
+
+	crypto.ResetNonceStorage()
+	defer crypto.ResetNonceStorage()
+
+	cfg := crypto.DefaultNonceCacheConfig()
+	ceiling := cfg.ShardCount * cfg.MaxEntriesPerShard
+
+	// Базовая линия: время проверки легитимного nonce до начала заливки.
+	baselineNonce := make([]byte, crypto.NonceSize)
+	baselineNonce[0] = 0xAA
+	baselineStart := time.Now()
+	if err := crypto.GetNonceCache().AddNonce(baselineNonce); err != nil {
+		return nonceExhaustionTestError(start, fmt.Sprintf("не удалось добавить базовый nonce: %v", err))
+	}
+	baselineElapsed := time.Since(baselineStart)
+
+	// Атака: заливаем кэш числом уникальных nonce, в floodMultiplier раз
+	// превышающим потолок памяти (ceiling), на пиковой скорости.
+	const floodMultiplier = 5
+	floodCount := ceiling * floodMultiplier
+	attempts := 0
+	for i := 0; i < floodCount; i++ {
+		nonce := make([]byte, crypto.NonceSize)
+		encodeFloodNonce(nonce, i)
+		_ = crypto.GetNonceCache().AddNonce(nonce)
+		attempts++
+	}
+
+	// Легитимное сообщение после заливки должно по-прежнему проверяться
+	// быстро (O(1)-ish): фиксируем отношение времени проверки к базовой линии.
+	legitNonce := make([]byte, crypto.NonceSize)
+	legitNonce[0] = 0xBB
+	legitStart := time.Now()
+	legitErr := crypto.GetNonceCache().AddNonce(legitNonce)
+	legitElapsed := time.Since(legitStart)
+
+	finalCount := crypto.GetNonceCache().Count()
+
+	memoryBounded := finalCount <= ceiling
+	stillFast := legitElapsed < 50*baselineElapsed+time.Millisecond
+	legitVerified := legitErr == nil
+
+	elapsed := time.Since(start)
+	successful := !memoryBounded || !stillFast || !legitVerified
+	attackProbability := 0.0
+	if successful {
+		attackProbability = 1.0
+	}
+
+	securityLevel := "ОТРАЖЕНО"
+	if successful {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Nonce Exhaustion DoS (ShardedNonceCache)",
+		Successful:        successful,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description: fmt.Sprintf(
+			"После заливки %d уникальных nonce (потолок %d записей) итоговый размер кэша %d; базовая проверка заняла %v, проверка после заливки — %v; легитимное сообщение принято: %v",
+			floodCount, ceiling, finalCount, baselineElapsed, legitElapsed, legitVerified,
+		),
+		Recommendation: "ShardedNonceCache: конфигурируемый потолок записей на шард плюс Bloom-фильтр ограничивают память и удерживают стоимость проверки почти постоянной под заливкой",
+	}
+}
+
+// encodeFloodNonce детерминированно заполняет nonce по индексу — для теста
+// важна лишь уникальность каждого значения, не криптографическая случайность.
+func encodeFloodNonce(nonce []byte, i int) {
+	for j := 0; j < len(nonce); j++ {
+		nonce[j] = byte(i >> (8 * (j % 4)))
+	}
+}
+
+func nonceExhaustionTestError(start time.Time, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        "Nonce Exhaustion DoS (ShardedNonceCache)",
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "ShardedNonceCache: конфигурируемый потолок записей на шард плюс Bloom-фильтр ограничивают память под заливкой",
+	}
+}
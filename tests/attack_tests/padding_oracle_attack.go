@@ -0,0 +1,172 @@
+package attack_tests
+
+import (
+	"client-server/internal/crypto"
+	"client-server/tests/metrics"
+	"fmt"
+	"time"
+)
+
+// TestPaddingOracleAttack проверяет, создают ли RSA-схемы шифрования
+// различимый оракул при повреждении ciphertext (классическая предпосылка
+// атаки Блейхенбахера на PKCS#1 v1.5). Для каждой схемы (устаревшей
+// RSAEncryptPKCS1v15/RSADecryptPKCS1v15 и рекомендуемой RSAEncryptOAEP/
+// RSADecryptOAEP) один и тот же шифротекст повреждается побайтовой заменой
+// в разных позициях, и сравниваются: (1) различимость возвращаемых типов
+// ошибок и (2) разброс времени расшифровки между позициями повреждения.
+// Уязвимой признается схема, в которой корректные и некорректные
+// по-разному расположенные повреждения дают различимые внешние эффекты.
+func TestPaddingOracleAttack() AttackTestResult {
+	start := time.Now()
+	stats := metrics.NewSecurityStats()
+
+	priv, pub := crypto.GenerateRSAKeys()
+	plaintext := []byte("padding oracle test payload")
+
+	pkcs1Ciphertext, err := crypto.RSAEncryptPKCS1v15(pub, plaintext, stats)
+	if err != nil {
+		return paddingOracleTestError(start, fmt.Sprintf("не удалось создать PKCS1v15 ciphertext: %v", err))
+	}
+	oaepCiphertext, err := crypto.RSAEncryptOAEP(pub, plaintext, stats)
+	if err != nil {
+		return paddingOracleTestError(start, fmt.Sprintf("не удалось создать OAEP ciphertext: %v", err))
+	}
+
+	// Позиции повреждения: начало (версия/padding-байты в PKCS1v15), середина
+	// и конец шифротекста — разные зоны ciphertext затрагивают разные этапы
+	// разбора padding.
+	positions := []int{1, len(pkcs1Ciphertext) / 2, len(pkcs1Ciphertext) - 1}
+
+	attempts := 0
+	pkcs1Distinguishable := false
+	oaepDistinguishable := false
+
+	var pkcs1ErrKinds = map[string]bool{}
+	var oaepErrKinds = map[string]bool{}
+	var pkcs1Times []time.Duration
+	var oaepTimes []time.Duration
+
+	for _, pos := range positions {
+		attempts++
+
+		corruptedPKCS1 := corruptByte(pkcs1Ciphertext, pos)
+		t0 := time.Now()
+		_, err := crypto.RSADecryptPKCS1v15(priv, corruptedPKCS1, stats)
+		pkcs1Times = append(pkcs1Times, time.Since(t0))
+		pkcs1ErrKinds[errKind(err)] = true
+
+		attempts++
+		corruptedOAEP := corruptByte(oaepCiphertext, pos)
+		t1 := time.Now()
+		_, err = crypto.RSADecryptOAEP(priv, corruptedOAEP, stats)
+		oaepTimes = append(oaepTimes, time.Since(t1))
+		oaepErrKinds[errKind(err)] = true
+	}
+
+	// Схема уязвима, если повреждения в разных позициях различимы по типу
+	// ошибки — одна и та же ошибка для всех позиций означает отсутствие
+	// оракула на уровне типов ошибок.
+	if len(pkcs1ErrKinds) > 1 {
+		pkcs1Distinguishable = true
+	}
+	if len(oaepErrKinds) > 1 {
+		oaepDistinguishable = true
+	}
+
+	pkcs1TimingSpread := timingSpread(pkcs1Times)
+	oaepTimingSpread := timingSpread(oaepTimes)
+
+	// Эмпирический порог: разброс времени расшифровки заметно больше
+	// характерного джиттера планировщика ОС считается потенциальным timing
+	// side-channel, усиливающим оракул по типам ошибок.
+	const timingSpreadThreshold = 2 * time.Millisecond
+	pkcs1TimingLeaky := pkcs1TimingSpread > timingSpreadThreshold
+	oaepTimingLeaky := oaepTimingSpread > timingSpreadThreshold
+
+	vulnerable := pkcs1Distinguishable || pkcs1TimingLeaky
+
+	elapsed := time.Since(start)
+	attackProbability := 0.0
+	if vulnerable {
+		attackProbability = 1.0
+	}
+	stats.SetAttackProbability(attackProbability)
+
+	securityLevel := "ОТРАЖЕНО"
+	if vulnerable {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "RSA Padding Oracle (Bleichenbacher-style)",
+		Successful:        vulnerable,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description: fmt.Sprintf(
+			"PKCS1v15: %d различимых типов ошибок, разброс времени %v; OAEP: %d различимых типов ошибок, разброс времени %v",
+			len(pkcs1ErrKinds), pkcs1TimingSpread, len(oaepErrKinds), oaepTimingSpread,
+		),
+		Recommendation: "Использовать RSAEncryptOAEP/RSADecryptOAEP для нового кода; RSAEncryptPKCS1v15 оставлять только для сравнения в тестах",
+		AdditionalMetrics: map[string]interface{}{
+			"pkcs1DistinguishableErrors": pkcs1Distinguishable,
+			"oaepDistinguishableErrors":  oaepDistinguishable,
+			"pkcs1TimingLeaky":           pkcs1TimingLeaky,
+			"oaepTimingLeaky":            oaepTimingLeaky,
+		},
+	}
+}
+
+// corruptByte возвращает копию data с перевернутыми битами байта в позиции
+// pos (не мутирует исходный срез).
+func corruptByte(data []byte, pos int) []byte {
+	corrupted := append([]byte(nil), data...)
+	if pos >= 0 && pos < len(corrupted) {
+		corrupted[pos] ^= 0xFF
+	}
+	return corrupted
+}
+
+// errKind возвращает стабильный строковый идентификатор ошибки для сравнения
+// различимости между позициями повреждения (nil считается отдельным видом).
+func errKind(err error) string {
+	if err == nil {
+		return "nil"
+	}
+	return err.Error()
+}
+
+// timingSpread возвращает разницу между максимальным и минимальным временем
+// в выборке измерений.
+func timingSpread(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	min, max := samples[0], samples[0]
+	for _, s := range samples {
+		if s < min {
+			min = s
+		}
+		if s > max {
+			max = s
+		}
+	}
+	return max - min
+}
+
+func paddingOracleTestError(start time.Time, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        "RSA Padding Oracle (Bleichenbacher-style)",
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "Использовать RSAEncryptOAEP/RSADecryptOAEP для нового кода",
+	}
+}
@@ -0,0 +1,35 @@
+package attack_tests
+
+import (
+	"client-server/internal/crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+)
+
+// attackSeedSize — размер seed для детерминированного источника энтропии
+// атак, передаваемого в crypto.NewDeterministicReader.
+const attackSeedSize = 32
+
+// newAttackSeed генерирует случайный seed для текущего прогона атаки.
+// Сохранение этого seed в AttackTestResult.AdditionalMetrics позволяет
+// воспроизвести неудавшийся прогон с той же последовательностью
+// "случайных" атакующих ключей через crypto.NewDeterministicReader.
+func newAttackSeed() []byte {
+	seed := make([]byte, attackSeedSize)
+	rand.Read(seed)
+	return seed
+}
+
+// seedHex форматирует seed атаки как hex-строку для AdditionalMetrics.
+func seedHex(seed []byte) string {
+	return hex.EncodeToString(seed)
+}
+
+// attackRand возвращает детерминированный io.Reader, производный от seed —
+// используется вместо crypto/rand внутри цикла перебора атаки, чтобы при
+// повторном запуске с тем же seed получить идентичную последовательность
+// атакующих ключей.
+func attackRand(seed []byte) io.Reader {
+	return crypto.NewDeterministicReader(seed)
+}
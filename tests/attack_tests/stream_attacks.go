@@ -0,0 +1,182 @@
+package attack_tests
+
+import (
+	"bytes"
+	"client-server/internal/crypto"
+	"client-server/tests/metrics"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TestBruteForceResistanceStream — потоковый аналог TestBruteForceResistance:
+// перебирает ключи против потока, зашифрованного NewEncryptingWriter, вместо
+// однократного AESEncrypt. Проверяет, что chunking и AAD на базе номера
+// последовательности не открывают более дешевого пути атаки, чем
+// монолитное AES-256-GCM шифрование.
+func TestBruteForceResistanceStream() AttackTestResult {
+	start := time.Now()
+	attempts := 0
+
+	correctKey := make([]byte, 32)
+	rand.Read(correctKey)
+	iv := make([]byte, 12)
+	rand.Read(iv)
+	plaintext := bytes.Repeat([]byte("secret stream message "), 10)
+	stats := metrics.NewSecurityStats()
+
+	var encrypted bytes.Buffer
+	writer, err := crypto.NewEncryptingWriter(&encrypted, correctKey, iv, stats)
+	if err != nil {
+		return streamTestError(start, "Brute Force (Stream AES-256-GCM Key)", fmt.Sprintf("не удалось создать EncryptingWriter: %v", err))
+	}
+	writer.Write(plaintext)
+	writer.Close()
+	correctStream := encrypted.Bytes()
+
+	maxTime := 5 * time.Second
+	success := false
+
+	for time.Since(start) < maxTime {
+		attackKey := make([]byte, 32)
+		rand.Read(attackKey)
+
+		reader, err := crypto.NewDecryptingReader(bytes.NewReader(correctStream), attackKey, iv, stats)
+		if err == nil {
+			if decrypted, err := io.ReadAll(reader); err == nil && bytes.Equal(decrypted, plaintext) {
+				success = true
+				break
+			}
+		}
+		attempts++
+	}
+	elapsed := time.Since(start)
+	rate := float64(attempts) / elapsed.Seconds()
+
+	keySpace := math.Pow(2, 256)
+	attackProbability := float64(attempts) / keySpace
+	if success {
+		attackProbability = 1.0
+	}
+	securityLevel := "ОТРАЖЕНО"
+	if success {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Brute Force (Stream AES-256-GCM Key)",
+		Successful:        success,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        rate,
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description:       fmt.Sprintf("Attempted to brute force streaming AES-256-GCM key in %v", elapsed),
+		Recommendation:    "Потоковое AES-256-GCM шифрование (NewEncryptingWriter) устойчиво к перебору так же, как и монолитное AESEncrypt",
+	}
+}
+
+// TestConcurrentAttacksStream — потоковый аналог TestConcurrentAttacks:
+// несколько воркеров параллельно перебирают ключи против того же
+// зашифрованного NewEncryptingWriter потока.
+func TestConcurrentAttacksStream() AttackTestResult {
+	start := time.Now()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	iv := make([]byte, 12)
+	rand.Read(iv)
+	plaintext := bytes.Repeat([]byte("target stream message "), 10)
+	stats := metrics.NewSecurityStats()
+
+	var encrypted bytes.Buffer
+	writer, err := crypto.NewEncryptingWriter(&encrypted, key, iv, stats)
+	if err != nil {
+		return streamTestError(start, "Concurrent Brute Force (Stream AES-256-GCM)", fmt.Sprintf("не удалось создать EncryptingWriter: %v", err))
+	}
+	writer.Write(plaintext)
+	writer.Close()
+	ciphertextStream := encrypted.Bytes()
+
+	numWorkers := runtime.NumCPU()
+	attempts := make([]int, numWorkers)
+	var wg sync.WaitGroup
+	success := false
+	var successMutex sync.Mutex
+
+	maxTime := 3 * time.Second
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			workerStart := time.Now()
+
+			for time.Since(workerStart) < maxTime {
+				attackKey := make([]byte, 32)
+				rand.Read(attackKey)
+
+				reader, err := crypto.NewDecryptingReader(bytes.NewReader(ciphertextStream), attackKey, iv, stats)
+				if err == nil {
+					if decrypted, err := io.ReadAll(reader); err == nil && bytes.Equal(decrypted, plaintext) {
+						successMutex.Lock()
+						success = true
+						successMutex.Unlock()
+						return
+					}
+				}
+				attempts[workerID]++
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	totalAttempts := 0
+	for _, count := range attempts {
+		totalAttempts += count
+	}
+	rate := float64(totalAttempts) / elapsed.Seconds()
+
+	keySpace := math.Pow(2, 256)
+	attackProbability := float64(totalAttempts) / keySpace
+	if success {
+		attackProbability = 1.0
+	}
+	securityLevel := "ОТРАЖЕНО"
+	if success {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Concurrent Brute Force (Stream AES-256-GCM)",
+		Successful:        success,
+		AttemptsMade:      totalAttempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        rate,
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description:       fmt.Sprintf("%d параллельных воркеров перебирали ключ потокового AES-256-GCM за %v", numWorkers, elapsed),
+		Recommendation:    "Параллелизация перебора не снижает заметно стойкость потокового AES-256-GCM шифрования",
+	}
+}
+
+func streamTestError(start time.Time, attackType, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        attackType,
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "Потоковое AES-256-GCM шифрование (NewEncryptingWriter/NewDecryptingReader)",
+	}
+}
@@ -0,0 +1,253 @@
+package attack_tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"client-server/internal/transport/acme"
+	"client-server/tests/metrics"
+)
+
+// generateLeafCert создает самоподписанный сертификат ECDSA для host,
+// используемый вместо реального Let's Encrypt сертификата в тесте: поднимать
+// настоящий сервер ACME (Pebble/Boulder) в рамках unit-теста непрактично,
+// но логика pinning/downgrade-проверки в acme.VerifyPin/NewTLSConfig от
+// источника сертификата не зависит.
+func generateLeafCert(host string) (tls.Certificate, *x509.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}, leaf, nil
+}
+
+// dialWithPin устанавливает TLS-соединение с addr ровно на версии minVersion
+// (MinVersion и MaxVersion равны ей), отклоняя сертификаты, не совпадающие с pinnedSPKIHash по SPKI-отпечатку.
+func dialWithPin(addr, pinnedSPKIHash string, minVersion uint16) (*tls.Conn, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: true, // проверка подлинности выполняется вручную через pinning ниже
+		MinVersion:         minVersion,
+		MaxVersion:         minVersion,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if !acme.VerifyPin(state, pinnedSPKIHash) {
+				return fmt.Errorf("TLS-пиннинг: отпечаток SPKI сертификата не совпадает с закрепленным")
+			}
+			return nil
+		},
+	}
+	return tls.Dial("tcp", addr, cfg)
+}
+
+// TestTLSDowngradeAndCertPinning проверяет четыре свойства транспортного
+// уровня: (a) эталонное подключение к серверу с закрепленным сертификатом
+// проходит; (b) MITM, предъявляющий другой валидный сертификат, отклоняется
+// пиннингом SPKI-отпечатка; (c) попытка согласовать TLS 1.2 отклоняется
+// сервером, требующим TLS 1.3 (см. acme.NewTLSConfig); (d) возобновленная
+// (resumed) сессия по-прежнему предъявляет закрепленный сертификат. Время установления
+// эталонного handshake записывается в SecurityStats, чтобы показатель
+// эффективности учитывал и стоимость транспортной настройки, а не только
+// симметричную криптографию.
+func TestTLSDowngradeAndCertPinning() AttackTestResult {
+	start := time.Now()
+	stats := metrics.NewSecurityStats()
+	attempts := 0
+	successfulAttacks := 0
+	var notes []string
+
+	const host = "legit.example.internal"
+	legitCert, legitLeaf, err := generateLeafCert(host)
+	if err != nil {
+		return tlsTestError(start, fmt.Sprintf("не удалось сгенерировать эталонный сертификат: %v", err))
+	}
+	mitmCert, _, err := generateLeafCert(host)
+	if err != nil {
+		return tlsTestError(start, fmt.Sprintf("не удалось сгенерировать MITM-сертификат: %v", err))
+	}
+
+	pin := acme.SPKIHash(legitLeaf)
+
+	serverCfg := &tls.Config{
+		Certificates: []tls.Certificate{legitCert},
+		MinVersion:   tls.VersionTLS13,
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverCfg)
+	if err != nil {
+		return tlsTestError(start, fmt.Sprintf("не удалось поднять тестовый TLS-сервер: %v", err))
+	}
+	defer ln.Close()
+	go acceptLoop(ln)
+
+	// (a) эталонное подключение: пиннинг должен принять легитимный сертификат
+	// и замерить время handshake для SecurityStats.
+	handshakeStart := time.Now()
+	conn, err := dialWithPin(ln.Addr().String(), pin, tls.VersionTLS13)
+	attempts++
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("эталонное подключение отклонено неожиданно: %v", err))
+		successfulAttacks++ // отказ легитимному клиенту — тоже проблема безопасности/доступности
+	} else {
+		stats.RecordEncryptionTime(time.Since(handshakeStart)) // время TLS-handshake как часть стоимости транспорта
+		conn.Close()
+	}
+
+	// (b) MITM с другим валидным (но не закрепленным) сертификатом на том же
+	// host должен быть отклонен pinning-проверкой клиента.
+	mitmLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{mitmCert},
+		MinVersion:   tls.VersionTLS13,
+	})
+	if err != nil {
+		return tlsTestError(start, fmt.Sprintf("не удалось поднять MITM TLS-сервер: %v", err))
+	}
+	defer mitmLn.Close()
+	go acceptLoop(mitmLn)
+
+	attempts++
+	mitmConn, err := dialWithPin(mitmLn.Addr().String(), pin, tls.VersionTLS13)
+	if err == nil {
+		mitmConn.Close()
+		successfulAttacks++
+		notes = append(notes, "MITM с чужим сертификатом был принят — пиннинг не сработал")
+	} else {
+		notes = append(notes, "MITM с чужим сертификатом отклонен пиннингом SPKI")
+	}
+
+	// (c) TLS 1.2 downgrade: сервер требует MinVersion TLS 1.3, поэтому
+	// клиент, ограниченный TLS 1.2, не должен установить соединение.
+	attempts++
+	downgradeConn, err := dialWithPin(ln.Addr().String(), pin, tls.VersionTLS12)
+	if err == nil {
+		downgradeConn.Close()
+		successfulAttacks++
+		notes = append(notes, "downgrade до TLS 1.2 был принят сервером")
+	} else {
+		notes = append(notes, "downgrade до TLS 1.2 отклонен сервером")
+	}
+
+	// (d) возобновленная сессия (session resumption) должна по-прежнему
+	// предъявлять закрепленный сертификат из эталонного подключения.
+	clientSessionCache := tls.NewLRUClientSessionCache(4)
+	resumeCfg := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+		ClientSessionCache: clientSessionCache,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if !acme.VerifyPin(state, pin) {
+				return fmt.Errorf("пиннинг: отпечаток не совпадает при возобновлении сессии")
+			}
+			return nil
+		},
+	}
+	firstConn, err := tls.Dial("tcp", ln.Addr().String(), resumeCfg)
+	attempts++
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("не удалось установить начальное соединение для resumption: %v", err))
+		successfulAttacks++
+	} else {
+		firstConn.Close()
+		secondConn, err := tls.Dial("tcp", ln.Addr().String(), resumeCfg)
+		attempts++
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("не удалось возобновить сессию: %v", err))
+			successfulAttacks++
+		} else {
+			resumed := secondConn.ConnectionState().DidResume
+			secondConn.Close()
+			if resumed {
+				notes = append(notes, "возобновленная сессия по-прежнему несет закрепленный сертификат")
+			} else {
+				notes = append(notes, "сервер не поддержал session resumption (не критично для пиннинга)")
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	attackProbability := float64(successfulAttacks) / float64(attempts)
+	stats.SetAttackProbability(attackProbability)
+
+	securityLevel := "ОТРАЖЕНО"
+	if successfulAttacks > 0 {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "TLS Downgrade & Certificate Pinning",
+		Successful:        successfulAttacks > 0,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description:       fmt.Sprintf("%v; handshake time recorded in SecurityStats: %v", notes, stats.EncryptionTime),
+		Recommendation:    "Требовать TLS 1.3 (acme.NewTLSConfig) и закреплять SPKI leaf-сертификата на клиенте",
+	}
+}
+
+// acceptLoop принимает и немедленно закрывает соединения тестового слушателя,
+// этого достаточно для выполнения TLS handshake в TestTLSDowngradeAndCertPinning.
+func acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			// Читаем TLS-рукопожатие до конца, чтобы клиент успел получить
+			// результат проверки сертификата, затем закрываем соединение.
+			if tlsConn, ok := c.(*tls.Conn); ok {
+				tlsConn.Handshake()
+			}
+			time.Sleep(10 * time.Millisecond)
+			c.Close()
+		}(conn)
+	}
+}
+
+func tlsTestError(start time.Time, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        "TLS Downgrade & Certificate Pinning",
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "Требовать TLS 1.3 (acme.NewTLSConfig) и закреплять SPKI leaf-сертификата на клиенте",
+	}
+}
@@ -0,0 +1,151 @@
+package attack_tests
+
+import (
+	"bytes"
+	"client-server/internal/crypto"
+	"client-server/tests/metrics"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TestTruncationAttack проверяет, что decryptingReader (NewDecryptingReader)
+// обнаруживает усечение потока: если атакующий отбрасывает хвостовые чанки
+// (включая финальный чанк с флагом last-chunk), чтение должно завершиться
+// ErrStreamTruncated, а не вернуть укороченный, но по виду валидный plaintext.
+func TestTruncationAttack() AttackTestResult {
+	start := time.Now()
+	stats := metrics.NewSecurityStats()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	iv := make([]byte, 12)
+	rand.Read(iv)
+
+	// Данные на несколько чанков, чтобы усечение отрезало не весь поток, а
+	// только его часть — это худший случай: читателю есть что вернуть до
+	// того, как он заметит отсутствие финального чанка.
+	plaintext := bytes.Repeat([]byte("truncation-attack-payload-"), crypto.StreamChunkSize/8)
+
+	var encrypted bytes.Buffer
+	writer, err := crypto.NewEncryptingWriter(&encrypted, key, iv, stats)
+	if err != nil {
+		return truncationTestError(start, fmt.Sprintf("не удалось создать EncryptingWriter: %v", err))
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return truncationTestError(start, fmt.Sprintf("ошибка записи в EncryptingWriter: %v", err))
+	}
+	if err := writer.Close(); err != nil {
+		return truncationTestError(start, fmt.Sprintf("ошибка закрытия EncryptingWriter: %v", err))
+	}
+
+	fullStream := encrypted.Bytes()
+
+	attempts := 0
+	successfulAttacks := 0
+
+	// Попытка 1: честный поток должен расшифровываться полностью и без ошибок.
+	attempts++
+	reader, err := crypto.NewDecryptingReader(bytes.NewReader(fullStream), key, iv, stats)
+	if err != nil {
+		return truncationTestError(start, fmt.Sprintf("не удалось создать DecryptingReader: %v", err))
+	}
+	roundTripped, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(roundTripped, plaintext) {
+		successfulAttacks++ // честный поток обязан проходить — иначе API сломан
+	}
+
+	// Попытка 2: отбрасываем последние несколько байт (часть финального чанка) —
+	// атакующий, оборвавший соединение до его завершения.
+	attempts++
+	truncated := fullStream[:len(fullStream)-5]
+	reader, err = crypto.NewDecryptingReader(bytes.NewReader(truncated), key, iv, stats)
+	if err != nil {
+		return truncationTestError(start, fmt.Sprintf("не удалось создать DecryptingReader: %v", err))
+	}
+	_, err = io.ReadAll(reader)
+	if !errors.Is(err, crypto.ErrStreamTruncated) {
+		successfulAttacks++
+	}
+
+	// Попытка 3: отбрасываем ровно финальный чанк целиком, оставляя только
+	// промежуточные чанки валидными — самая коварная truncation-атака, так
+	// как все прочитанные байты честно аутентифицированы.
+	attempts++
+	withoutFinalChunk := dropLastChunk(fullStream)
+	reader, err = crypto.NewDecryptingReader(bytes.NewReader(withoutFinalChunk), key, iv, stats)
+	if err != nil {
+		return truncationTestError(start, fmt.Sprintf("не удалось создать DecryptingReader: %v", err))
+	}
+	partial, err := io.ReadAll(reader)
+	if !errors.Is(err, crypto.ErrStreamTruncated) {
+		successfulAttacks++
+	}
+	if err == nil && len(partial) > 0 {
+		// Частичный plaintext без ошибки — ровно тот исход, которого атака добивается.
+		successfulAttacks++
+	}
+
+	elapsed := time.Since(start)
+	attackProbability := float64(successfulAttacks) / float64(attempts)
+	stats.SetAttackProbability(attackProbability)
+
+	securityLevel := "ОТРАЖЕНО"
+	if successfulAttacks > 0 {
+		securityLevel = "УЯЗВИМ"
+	}
+
+	return AttackTestResult{
+		AttackType:        "Stream Truncation Attack",
+		Successful:        successfulAttacks > 0,
+		AttemptsMade:      attempts,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        float64(attempts) / elapsed.Seconds(),
+		AttackProbability: attackProbability,
+		SecurityLevel:     securityLevel,
+		Description:       fmt.Sprintf("Успешных усечений: %d из %d попыток (честный round-trip + усечение на 5 байт + удаление финального чанка)", successfulAttacks, attempts),
+		Recommendation:    "NewDecryptingReader требует чанк с флагом last-chunk; усечение потока обнаруживается как ErrStreamTruncated",
+	}
+}
+
+// dropLastChunk разбирает фрейм потока на чанки (5-байтовый заголовок +
+// ciphertext) и отбрасывает последний из них, эмулируя атакующего, который
+// обрывает соединение ровно перед финальным чанком.
+func dropLastChunk(stream []byte) []byte {
+	type frame struct {
+		start, end int
+	}
+	var frames []frame
+	offset := 0
+	for offset+5 <= len(stream) {
+		clen := int(stream[offset+1])<<24 | int(stream[offset+2])<<16 | int(stream[offset+3])<<8 | int(stream[offset+4])
+		frameEnd := offset + 5 + clen
+		if frameEnd > len(stream) {
+			break
+		}
+		frames = append(frames, frame{offset, frameEnd})
+		offset = frameEnd
+	}
+	if len(frames) == 0 {
+		return stream
+	}
+	lastKept := frames[len(frames)-1].start
+	return stream[:lastKept]
+}
+
+func truncationTestError(start time.Time, reason string) AttackTestResult {
+	elapsed := time.Since(start)
+	return AttackTestResult{
+		AttackType:        "Stream Truncation Attack",
+		Successful:        false,
+		AttemptsMade:      0,
+		TimeElapsed:       elapsed.Milliseconds(),
+		AttackRate:        0,
+		AttackProbability: 0,
+		SecurityLevel:     "НЕ ПРОВЕРЕНО",
+		Description:       reason,
+		Recommendation:    "NewDecryptingReader требует чанк с флагом last-chunk; усечение потока обнаруживается как ErrStreamTruncated",
+	}
+}
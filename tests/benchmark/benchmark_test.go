@@ -1,12 +1,14 @@
 package benchmark
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"io"
 	"testing"
 
 	"client-server/internal/crypto"
@@ -137,6 +139,97 @@ func BenchmarkVerifyAndDecryptMessage(b *testing.B) {
 	}
 }
 
+func BenchmarkKeyGenerationMatrix(b *testing.B) {
+	for _, profile := range crypto.AlgorithmProfiles() {
+		profile := profile
+		b.Run(profile.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := crypto.GenerateKeyPair(profile); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSignVerifyMatrix(b *testing.B) {
+	stats := metrics.NewSecurityStats()
+	data := make([]byte, 1024)
+	rand.Read(data)
+
+	for _, profile := range crypto.AlgorithmProfiles() {
+		keyPair, err := crypto.GenerateKeyPair(profile)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sig, err := crypto.SignWithKeyPair(keyPair, data, stats)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(profile.String()+"/Sign", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				crypto.SignWithKeyPair(keyPair, data, stats)
+			}
+		})
+
+		b.Run(profile.String()+"/Verify", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				crypto.VerifyWithKeyPair(keyPair, data, sig, stats)
+			}
+		})
+	}
+}
+
+func BenchmarkHybridKEM(b *testing.B) {
+	kp, pub, err := crypto.GenerateHybridKEM()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ct, _, err := crypto.HybridEncapsulate(pub)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := crypto.HybridDecapsulate(kp, ct); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHybridSign(b *testing.B) {
+	stats := metrics.NewSecurityStats()
+	kp, err := crypto.GenerateHybridSignKeyPair()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 1024)
+	rand.Read(data)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		crypto.HybridSign(kp, data, stats)
+	}
+}
+
+func BenchmarkHybridVerify(b *testing.B) {
+	stats := metrics.NewSecurityStats()
+	kp, err := crypto.GenerateHybridSignKeyPair()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 1024)
+	rand.Read(data)
+	sig := crypto.HybridSign(kp, data, stats)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		crypto.HybridVerify(kp, data, sig, stats)
+	}
+}
+
 func BenchmarkDifferentMessageSizes(b *testing.B) {
 	stats := metrics.NewSecurityStats()
 	key := make([]byte, 32)
@@ -165,4 +258,136 @@ func BenchmarkDifferentMessageSizes(b *testing.B) {
 			}
 		})
 	}
+
+	// На 1 МиБ и 16 МиБ одноразовый AESEncrypt выше уже держит весь
+	// plaintext+ciphertext в памяти одновременно — добавляем потоковые
+	// варианты тех же размеров, чтобы было видно, где это становится
+	// проблемой (см. StreamingMode в tests/benchmark/load_tests.go).
+	streamIV := make([]byte, 12)
+	rand.Read(streamIV)
+	for _, size := range []int{1024 * 1024, 16 * 1024 * 1024} {
+		b.Run(fmt.Sprintf("StreamEncrypt-%dB", size), func(b *testing.B) {
+			plaintext := make([]byte, size)
+			rand.Read(plaintext)
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				writer, err := crypto.NewEncryptingWriter(io.Discard, key, streamIV, stats)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := writer.Write(plaintext); err != nil {
+					b.Fatal(err)
+				}
+				if err := writer.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("StreamDecrypt-%dB", size), func(b *testing.B) {
+			plaintext := make([]byte, size)
+			rand.Read(plaintext)
+			var encrypted bytes.Buffer
+			writer, err := crypto.NewEncryptingWriter(&encrypted, key, streamIV, stats)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := writer.Write(plaintext); err != nil {
+				b.Fatal(err)
+			}
+			if err := writer.Close(); err != nil {
+				b.Fatal(err)
+			}
+			ciphertext := encrypted.Bytes()
+
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reader, err := crypto.NewDecryptingReader(bytes.NewReader(ciphertext), key, streamIV, stats)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, reader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamEncrypt измеряет пропускную способность потокового
+// шифрования (crypto.NewEncryptingWriter) на наборе размеров payload —
+// аналог BenchmarkEncryptWriter из restic.
+func BenchmarkStreamEncrypt(b *testing.B) {
+	stats := metrics.NewSecurityStats()
+	key := make([]byte, 32)
+	rand.Read(key)
+	iv := make([]byte, 12)
+	rand.Read(iv)
+
+	for _, size := range []int{1024, 64 * 1024, 1024 * 1024, 16 * 1024 * 1024} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				writer, err := crypto.NewEncryptingWriter(io.Discard, key, iv, stats)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := writer.Write(plaintext); err != nil {
+					b.Fatal(err)
+				}
+				if err := writer.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamDecrypt измеряет пропускную способность потоковой
+// расшифровки (crypto.NewDecryptingReader) — аналог BenchmarkDecryptReader из
+// restic.
+func BenchmarkStreamDecrypt(b *testing.B) {
+	stats := metrics.NewSecurityStats()
+	key := make([]byte, 32)
+	rand.Read(key)
+	iv := make([]byte, 12)
+	rand.Read(iv)
+
+	for _, size := range []int{1024, 64 * 1024, 1024 * 1024, 16 * 1024 * 1024} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		var encrypted bytes.Buffer
+		writer, err := crypto.NewEncryptingWriter(&encrypted, key, iv, stats)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := writer.Write(plaintext); err != nil {
+			b.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			b.Fatal(err)
+		}
+		ciphertext := encrypted.Bytes()
+
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reader, err := crypto.NewDecryptingReader(bytes.NewReader(ciphertext), key, iv, stats)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, reader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
 }
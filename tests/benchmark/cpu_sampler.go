@@ -0,0 +1,125 @@
+package benchmark
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CPUSampleInterval — интервал, с которым cpuSampler снимает показания CPU и
+// количество горутин во время runLoadTestWithProfile. Экспортирован, чтобы
+// вызывающий код мог сделать сэмплирование более частым/редким без изменения
+// сигнатур нагрузочных функций.
+var CPUSampleInterval = 100 * time.Millisecond
+
+// cpuSampler периодически снимает загрузку CPU процесса через
+// syscall.Getrusage(RUSAGE_SELF, ...), заменяя прежнюю формулу
+// calculateCPUUsage (константная оценка "10 + concurrency*1.5") на реальные
+// измерения. syscall.Rusage.Utime/Stime и метод Timeval.Nano() определены
+// одинаково в пакете syscall и на Linux, и на Darwin, поэтому в отличие от
+// чтения /proc/self/stat (только Linux) этот способ не требует отдельных
+// файлов со сборочными тегами для каждой платформы.
+type cpuSampler struct {
+	stop chan struct{}
+	done chan struct{}
+
+	mu            sync.Mutex
+	samples       []float64
+	goroutinePeak int
+}
+
+// startCPUSampling запускает фоновую горутину, снимающую загрузку CPU и пик
+// runtime.NumGoroutine() с интервалом CPUSampleInterval, пока не будет вызван
+// Stop.
+func startCPUSampling() *cpuSampler {
+	s := &cpuSampler{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *cpuSampler) run() {
+	defer close(s.done)
+
+	lastCPU := cpuTimeNow()
+	lastWall := time.Now()
+	s.recordGoroutines()
+
+	ticker := time.NewTicker(CPUSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			cpu := cpuTimeNow()
+			wall := now.Sub(lastWall)
+			if wall > 0 {
+				percent := float64(cpu-lastCPU) / float64(wall) / float64(runtime.NumCPU()) * 100.0
+				s.mu.Lock()
+				s.samples = append(s.samples, percent)
+				s.mu.Unlock()
+			}
+			lastCPU = cpu
+			lastWall = now
+			s.recordGoroutines()
+		}
+	}
+}
+
+func (s *cpuSampler) recordGoroutines() {
+	n := runtime.NumGoroutine()
+	s.mu.Lock()
+	if n > s.goroutinePeak {
+		s.goroutinePeak = n
+	}
+	s.mu.Unlock()
+}
+
+// cpuTimeNow возвращает суммарное пользовательское и системное CPU-время
+// процесса с момента его запуска.
+func cpuTimeNow() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// Stop останавливает сэмплирование и возвращает среднюю и 95-й перцентиль
+// загрузки CPU (в процентах), а также пик числа горутин за время наблюдения.
+func (s *cpuSampler) Stop() (mean float64, p95 float64, goroutinePeak int) {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goroutinePeak = s.goroutinePeak
+	if len(s.samples) == 0 {
+		return 0, 0, goroutinePeak
+	}
+
+	sorted := make([]float64, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean = sum / float64(len(sorted))
+
+	idx := int(float64(len(sorted)) * 0.95)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	p95 = sorted[idx]
+
+	return mean, p95, goroutinePeak
+}
@@ -0,0 +1,266 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latencyBucketsMs — границы гистограммы латентности в миллисекундах для
+// ExportResultsPrometheus, по аналогии со стандартными bucket'ами
+// client_golang (DefBuckets), но в миллисекундах — сами операции шифрования
+// в этом пакете всюду измеряются в миллисекундах, а не секундах.
+var latencyBucketsMs = []float64{0.1, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// ExportResultsPrometheus записывает results в виде текстового файла
+// OpenMetrics (https://openmetrics.io): по одному gauge-семейству на
+// метрику с метками client/algo (throughput, латентность, error rate,
+// память, CPU, паузы GC), плюс гистограмма времени шифрования/расшифровки
+// отдельных операций, построенная из LoadTestResult.Operations. Требует,
+// чтобы Operations был заполнен (см. комментарий к полю в LoadTestResult) —
+// иначе гистограмма пишется с нулевым count.
+func ExportResultsPrometheus(results map[ClientType]LoadTestResult, filename string) error {
+	var buf bytes.Buffer
+	writeOpenMetrics(&buf, results)
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	fmt.Printf("Результаты экспортированы в OpenMetrics: %s\n", filename)
+	return nil
+}
+
+// PushResultsToGateway отправляет results в Prometheus Pushgateway по
+// адресу gatewayURL. Используется PUT (а не POST): PUT полностью заменяет
+// метрики job "cryptobench" в Pushgateway, что нужно для сравнения сборок —
+// POST бы только дополнял предыдущий набор метрик тем же job.
+func PushResultsToGateway(gatewayURL string, results map[ClientType]LoadTestResult) error {
+	var buf bytes.Buffer
+	writeOpenMetrics(&buf, results)
+
+	url := strings.TrimRight(gatewayURL, "/") + "/metrics/job/cryptobench"
+	req, err := http.NewRequest(http.MethodPut, url, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to build pushgateway request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to gateway: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway вернул статус %d", resp.StatusCode)
+	}
+
+	fmt.Printf("Результаты отправлены в pushgateway: %s\n", url)
+	return nil
+}
+
+// writeOpenMetrics пишет OpenMetrics-представление results в buf — общая
+// часть ExportResultsPrometheus и PushResultsToGateway.
+func writeOpenMetrics(buf *bytes.Buffer, results map[ClientType]LoadTestResult) {
+	clientTypes := sortedClientTypes(results)
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_throughput_ops_per_second gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_throughput_ops_per_second{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.ThroughputOpsPerSec)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_throughput_mb_per_second gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_throughput_mb_per_second{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.ThroughputMBPerSec)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_encryption_latency_ms gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_encryption_latency_ms{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.AvgEncryptionTime)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_decryption_latency_ms gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_decryption_latency_ms{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.AvgDecryptionTime)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_encryption_latency_p99_ms gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_encryption_latency_p99_ms{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.EncP99Ms)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_decryption_latency_p99_ms gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_decryption_latency_p99_ms{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.DecP99Ms)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_error_rate gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_error_rate{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.ErrorRate)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_memory_mb gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_memory_mb{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.MemoryUsageMB)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_cpu_usage_percent gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_cpu_usage_percent{client=%q,algo=%q,quantile=\"mean\"} %f\n", string(ct), r.Algorithm, r.CPUUsagePercent)
+		fmt.Fprintf(buf, "cryptobench_cpu_usage_percent{client=%q,algo=%q,quantile=\"p95\"} %f\n", string(ct), r.Algorithm, r.CPUUsageP95)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_gc_pause_ms gauge\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		fmt.Fprintf(buf, "cryptobench_gc_pause_ms{client=%q,algo=%q} %f\n", string(ct), r.Algorithm, r.GCPauseMs)
+	}
+
+	fmt.Fprintf(buf, "# TYPE cryptobench_operation_latency_ms histogram\n")
+	for _, ct := range clientTypes {
+		r := results[ct]
+		writeLatencyHistogram(buf, ct, r.Algorithm, "encryption", operationDurationsMs(r.Operations, func(op OperationResult) time.Duration { return op.EncryptionTime }))
+		writeLatencyHistogram(buf, ct, r.Algorithm, "decryption", operationDurationsMs(r.Operations, func(op OperationResult) time.Duration { return op.DecryptionTime }))
+	}
+
+	fmt.Fprint(buf, "# EOF\n")
+}
+
+// writeLatencyHistogram пишет одну серию гистограммы OpenMetrics
+// (cryptobench_operation_latency_ms_bucket/_sum/_count) для клиента client и
+// операции op (encryption/decryption) по отсортированным durationsMs.
+func writeLatencyHistogram(buf *bytes.Buffer, client ClientType, algo, op string, durationsMs []float64) {
+	sort.Float64s(durationsMs)
+
+	sum := 0.0
+	for _, d := range durationsMs {
+		sum += d
+	}
+
+	cumulative := 0
+	for _, le := range latencyBucketsMs {
+		for cumulative < len(durationsMs) && durationsMs[cumulative] <= le {
+			cumulative++
+		}
+		fmt.Fprintf(buf, "cryptobench_operation_latency_ms_bucket{client=%q,algo=%q,op=%q,le=%q} %d\n",
+			string(client), algo, op, strconv.FormatFloat(le, 'f', -1, 64), cumulative)
+	}
+	fmt.Fprintf(buf, "cryptobench_operation_latency_ms_bucket{client=%q,algo=%q,op=%q,le=\"+Inf\"} %d\n", string(client), algo, op, len(durationsMs))
+	fmt.Fprintf(buf, "cryptobench_operation_latency_ms_sum{client=%q,algo=%q,op=%q} %f\n", string(client), algo, op, sum)
+	fmt.Fprintf(buf, "cryptobench_operation_latency_ms_count{client=%q,algo=%q,op=%q} %d\n", string(client), algo, op, len(durationsMs))
+}
+
+// operationDurationsMs извлекает из ops продолжительность (в миллисекундах),
+// выбранную pick, для успешных операций — латентность неудачных операций
+// (результат ошибки, а не шифрования) не показательна для гистограммы.
+func operationDurationsMs(ops []OperationResult, pick func(OperationResult) time.Duration) []float64 {
+	out := make([]float64, 0, len(ops))
+	for _, op := range ops {
+		if !op.Success {
+			continue
+		}
+		out = append(out, float64(pick(op).Milliseconds()))
+	}
+	return out
+}
+
+// sortedClientTypes возвращает ключи results в стабильном (отсортированном)
+// порядке — так же, как это уже делает CompareClientPerformance для вывода
+// в консоль, только здесь порядок нужен для детерминированности файла
+// экспорта, а не для ранжирования.
+func sortedClientTypes(results map[ClientType]LoadTestResult) []ClientType {
+	out := make([]ClientType, 0, len(results))
+	for ct := range results {
+		out = append(out, ct)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// ExportResultsCSV экспортирует results в CSV — по одной строке на тип
+// клиента, для загрузки в электронные таблицы (в отличие от ExportResults,
+// рассчитанного на программную обработку JSON).
+func ExportResultsCSV(results map[ClientType]LoadTestResult, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	cw := csv.NewWriter(f)
+
+	header := []string{
+		"clientType", "algorithm", "encryptionMethod", "concurrentUsers", "totalOperations",
+		"successfulOperations", "failedOperations", "errorRate",
+		"avgEncryptionTimeMs", "avgDecryptionTimeMs", "avgSigningTimeMs", "avgVerificationTimeMs",
+		"encP50Ms", "encP95Ms", "encP99Ms", "encMaxMs",
+		"decP50Ms", "decP95Ms", "decP99Ms", "decMaxMs",
+		"throughputOpsPerSec", "throughputMBPerSec", "testDurationMs",
+		"memoryUsageMB", "cpuUsagePercent", "cpuUsageP95Percent", "goroutinesPeak", "gcPauseMs",
+		"efficiencyScore",
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	for _, ct := range sortedClientTypes(results) {
+		r := results[ct]
+		row := []string{
+			string(r.ClientType),
+			r.Algorithm,
+			r.EncryptionMethod,
+			strconv.Itoa(r.ConcurrentUsers),
+			strconv.Itoa(r.TotalOperations),
+			strconv.Itoa(r.SuccessfulOps),
+			strconv.Itoa(r.FailedOps),
+			strconv.FormatFloat(r.ErrorRate, 'f', -1, 64),
+			strconv.FormatFloat(r.AvgEncryptionTime, 'f', -1, 64),
+			strconv.FormatFloat(r.AvgDecryptionTime, 'f', -1, 64),
+			strconv.FormatFloat(r.AvgSigningTime, 'f', -1, 64),
+			strconv.FormatFloat(r.AvgVerificationTime, 'f', -1, 64),
+			strconv.FormatFloat(r.EncP50Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.EncP95Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.EncP99Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.EncMaxMs, 'f', -1, 64),
+			strconv.FormatFloat(r.DecP50Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.DecP95Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.DecP99Ms, 'f', -1, 64),
+			strconv.FormatFloat(r.DecMaxMs, 'f', -1, 64),
+			strconv.FormatFloat(r.ThroughputOpsPerSec, 'f', -1, 64),
+			strconv.FormatFloat(r.ThroughputMBPerSec, 'f', -1, 64),
+			strconv.FormatInt(r.TestDuration, 10),
+			strconv.FormatFloat(r.MemoryUsageMB, 'f', -1, 64),
+			strconv.FormatFloat(r.CPUUsagePercent, 'f', -1, 64),
+			strconv.FormatFloat(r.CPUUsageP95, 'f', -1, 64),
+			strconv.Itoa(r.GoroutinesPeak),
+			strconv.FormatFloat(r.GCPauseMs, 'f', -1, 64),
+			strconv.FormatFloat(r.EfficiencyScore, 'f', -1, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush csv: %v", err)
+	}
+
+	fmt.Printf("Результаты экспортированы в CSV: %s\n", filename)
+	return nil
+}
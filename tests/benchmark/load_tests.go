@@ -1,11 +1,15 @@
 package benchmark
 
 import (
+	"bytes"
 	"client-server/internal/crypto"
 	"client-server/tests/metrics"
+	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"runtime"
 	"sort"
@@ -22,6 +26,13 @@ const (
 	WebClient     ClientType = "Web"
 	DesktopClient ClientType = "Desktop"
 	ServerClient  ClientType = "Server"
+	// ServerClientPQ — тот же профиль нагрузки, что и ServerClient, но с
+	// Algorithm, указывающим на crypto.AlgorithmHybridPQ (Dilithium3+Ed25519),
+	// что заставляет performCryptoOperation подписывать/проверять через
+	// HybridSign/HybridVerify вместо обычного ECDSA/RSA/Ed25519 — позволяет
+	// сравнить стоимость PQ-миграции с текущим baseline на одинаковой
+	// нагрузке.
+	ServerClientPQ ClientType = "ServerPQ"
 )
 
 // PerformanceProfile определяет профиль производительности для разных типов клиентов
@@ -31,6 +42,32 @@ type PerformanceProfile struct {
 	ConcurrentUsers int
 	TestDuration    time.Duration
 	Description     string
+	// Algorithm — ячейка матрицы асимметричных примитивов (см.
+	// crypto.AlgorithmProfiles), используемых этим профилем для
+	// подписи/проверки в runLoadTestWithProfile. Нулевое значение
+	// интерпретируется как crypto.AlgorithmECDSA на P256 — тот же
+	// зафиксированный путь, что использовался до появления матрицы.
+	// RunComprehensiveLoadTests не читает Algorithm, EncryptionMethod,
+	// StreamingMode и ChunkSize этого типа: RunLoadTestWithParams берет
+	// ячейку матрицы из GetClientProfile(ClientType) (для профилей
+	// GetDefaultProfiles значения совпадают).
+	Algorithm crypto.AlgorithmProfile
+	// EncryptionMethod — AEAD-набор (или легаси AES-CBC), которым
+	// runLoadTestWithProfile шифрует payload. Нулевое значение
+	// (EncryptionMethodPlain) сохраняет исходное поведение.
+	EncryptionMethod EncryptionMethod
+	// StreamingMode переключает performCryptoOperation на потоковое
+	// шифрование через crypto.NewEncryptingWriterWithChunkSize/
+	// NewDecryptingReader фиксированными чанками вместо одноразового
+	// AESEncrypt/AEAD.Seal над всем буфером — позволяет измерить, где
+	// единоразовый API упирается в память на больших сообщениях (см.
+	// BenchmarkDifferentMessageSizes). При включенном StreamingMode
+	// EncryptionMethod игнорируется: поток всегда шифруется AES-256-GCM
+	// (см. NewEncryptingWriter).
+	StreamingMode bool
+	// ChunkSize — размер чанка потокового шифрования при StreamingMode.
+	// Нулевое значение интерпретируется как crypto.StreamChunkSize (64 КиБ).
+	ChunkSize int
 }
 
 // LoadTestResult представляет результаты нагрузочного теста
@@ -42,23 +79,52 @@ type LoadTestResult struct {
 	FailedOps           int        `json:"failedOperations"`
 	AvgEncryptionTime   float64    `json:"avgEncryptionTimeMs"`
 	AvgDecryptionTime   float64    `json:"avgDecryptionTimeMs"`
+	AvgSigningTime      float64    `json:"avgSigningTimeMs"`
+	AvgVerificationTime float64    `json:"avgVerificationTimeMs"`
+	// EncP50Ms/EncP95Ms/EncP99Ms/EncMaxMs и их Dec-аналоги — перцентили
+	// латентности шифрования/расшифровки по успешным операциям, в отличие от
+	// Avg*Time, которые скрывают tail latency (например, паузы GC,
+	// затрагивающие лишь небольшую долю операций). Вычисляются в
+	// runLoadTestWithProfile из LoadTestResult.Operations.
+	EncP50Ms float64 `json:"encP50Ms"`
+	EncP95Ms float64 `json:"encP95Ms"`
+	EncP99Ms float64 `json:"encP99Ms"`
+	EncMaxMs float64 `json:"encMaxMs"`
+	DecP50Ms float64 `json:"decP50Ms"`
+	DecP95Ms float64 `json:"decP95Ms"`
+	DecP99Ms float64 `json:"decP99Ms"`
+	DecMaxMs float64 `json:"decMaxMs"`
+	Algorithm           string     `json:"algorithm"`
+	EncryptionMethod    string     `json:"encryptionMethod"`
 	ThroughputOpsPerSec float64    `json:"throughputOpsPerSec"`
+	ThroughputMBPerSec  float64    `json:"throughputMBPerSec"`
 	TestDuration        int64      `json:"testDurationMs"`
 	MemoryUsageMB       float64    `json:"memoryUsageMB"`
 	CPUUsagePercent     float64    `json:"cpuUsagePercent"`
+	CPUUsageP95         float64    `json:"cpuUsageP95Percent"`
+	GoroutinesPeak      int        `json:"goroutinesPeak"`
+	GCPauseMs           float64    `json:"gcPauseMs"`
 	ErrorRate           float64    `json:"errorRate"`
 	EfficiencyScore     float64    `json:"efficiencyScore"`
+	// Operations — результаты отдельных операций, собранные
+	// runLoadTestWithProfile. Исключены из JSON (см. ExportResults), чтобы не
+	// раздувать JSON-экспорт всеми операциями теста — используются только
+	// экспортом OpenMetrics (ExportResultsPrometheus/PushResultsToGateway) для
+	// построения гистограммы латентности.
+	Operations []OperationResult `json:"-"`
 }
 
 // OperationResult представляет результат одной криптографической операции
 type OperationResult struct {
-	OperationID    int           `json:"operationId"`
-	Success        bool          `json:"success"`
-	EncryptionTime time.Duration `json:"encryptionTime"`
-	DecryptionTime time.Duration `json:"decryptionTime"`
-	Error          error         `json:"error,omitempty"`
-	MessageSize    int           `json:"messageSize"`
-	Timestamp      time.Time     `json:"timestamp"`
+	OperationID      int           `json:"operationId"`
+	Success          bool          `json:"success"`
+	EncryptionTime   time.Duration `json:"encryptionTime"`
+	DecryptionTime   time.Duration `json:"decryptionTime"`
+	SigningTime      time.Duration `json:"signingTime"`
+	VerificationTime time.Duration `json:"verificationTime"`
+	Error            error         `json:"error,omitempty"`
+	MessageSize      int           `json:"messageSize"`
+	Timestamp        time.Time     `json:"timestamp"`
 }
 
 // ClientProfile определяет характеристики разных типов клиентов
@@ -67,6 +133,74 @@ type ClientProfile struct {
 	MessageSize     int
 	OperationsCount int
 	TestDuration    time.Duration
+	// Algorithm — ячейка матрицы асимметричных примитивов, см.
+	// PerformanceProfile.Algorithm.
+	Algorithm crypto.AlgorithmProfile
+	// EncryptionMethod — см. PerformanceProfile.EncryptionMethod.
+	EncryptionMethod EncryptionMethod
+	// StreamingMode — см. PerformanceProfile.StreamingMode.
+	StreamingMode bool
+	// ChunkSize — см. PerformanceProfile.ChunkSize.
+	ChunkSize int
+}
+
+// defaultAlgorithmProfile — ячейка матрицы, воспроизводящая зафиксированный
+// путь, существовавший до появления AlgorithmProfile (ECDSA на P256).
+func defaultAlgorithmProfile() crypto.AlgorithmProfile {
+	return crypto.AlgorithmProfile{Algorithm: crypto.AlgorithmECDSA, Curve: elliptic.P256()}
+}
+
+// EncryptionMethod перечисляет способы шифрования полезной нагрузки в
+// нагрузочном тесте. EncryptionMethodPlain воспроизводит исходный способ
+// (AES-256-CBC без MAC с ручной проверкой первого/последнего байта вместо
+// настоящей аутентификации) — сохранен для обратной совместимости отчетов;
+// остальные варианты — это настоящие AEAD-шифры поверх crypto.AEAD
+// (см. crypto.AEADBySuite), чей тег аутентификации заменяет побайтовую
+// проверку реальной криптографической проверкой подлинности.
+type EncryptionMethod int
+
+const (
+	EncryptionMethodPlain EncryptionMethod = iota
+	EncryptionMethodAESGCM
+	EncryptionMethodChaCha20Poly1305
+)
+
+// String возвращает человекочитаемое имя способа шифрования.
+func (m EncryptionMethod) String() string {
+	switch m {
+	case EncryptionMethodPlain:
+		return "AES-256-CBC (legacy)"
+	case EncryptionMethodAESGCM:
+		return crypto.SuiteAES256GCM.String()
+	case EncryptionMethodChaCha20Poly1305:
+		return crypto.SuiteChaCha20Poly1305.String()
+	default:
+		return "неизвестный EncryptionMethod"
+	}
+}
+
+// cipherSuite отображает EncryptionMethodAESGCM/ChaCha20Poly1305 на
+// соответствующий crypto.CipherSuite для crypto.AEADBySuite.
+// EncryptionMethodPlain не является AEAD-методом и обрабатывается отдельной веткой в
+// performCryptoOperation.
+func (m EncryptionMethod) cipherSuite() crypto.CipherSuite {
+	if m == EncryptionMethodChaCha20Poly1305 {
+		return crypto.SuiteChaCha20Poly1305
+	}
+	return crypto.SuiteAES256GCM
+}
+
+// defaultEncryptionMethod выбирает AEAD-набор по умолчанию для clientType в
+// духе Cloak-style per-client negotiation: ChaCha20-Poly1305 для
+// Mobile/Web (быстрее на ARM без аппаратного ускорения AES-NI),
+// AES-GCM для Desktop/Server (обычно x86 с AES-NI).
+func defaultEncryptionMethod(clientType ClientType) EncryptionMethod {
+	switch clientType {
+	case MobileClient, WebClient:
+		return EncryptionMethodChaCha20Poly1305
+	default:
+		return EncryptionMethodAESGCM
+	}
 }
 
 // GetClientProfile возвращает профиль для указанного типа клиента
@@ -74,39 +208,60 @@ func GetClientProfile(clientType ClientType) ClientProfile {
 	switch clientType {
 	case MobileClient:
 		return ClientProfile{
-			MaxConcurrency:  10,  // Ограниченная мощность
-			MessageSize:     512, // Меньшие сообщения
-			OperationsCount: 100,
-			TestDuration:    30 * time.Second,
+			MaxConcurrency:   10,  // Ограниченная мощность
+			MessageSize:      512, // Меньшие сообщения
+			OperationsCount:  100,
+			TestDuration:     30 * time.Second,
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(MobileClient),
 		}
 	case WebClient:
 		return ClientProfile{
-			MaxConcurrency:  25,
-			MessageSize:     1024,
-			OperationsCount: 250,
-			TestDuration:    30 * time.Second,
+			MaxConcurrency:   25,
+			MessageSize:      1024,
+			OperationsCount:  250,
+			TestDuration:     30 * time.Second,
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(WebClient),
 		}
 	case DesktopClient:
 		return ClientProfile{
-			MaxConcurrency:  50,
-			MessageSize:     2048,
-			OperationsCount: 500,
-			TestDuration:    30 * time.Second,
+			MaxConcurrency:   50,
+			MessageSize:      2048,
+			OperationsCount:  500,
+			TestDuration:     30 * time.Second,
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(DesktopClient),
 		}
 	case ServerClient:
 		return ClientProfile{
-			MaxConcurrency:  100,
-			MessageSize:     4096,
-			OperationsCount: 1000,
-			TestDuration:    30 * time.Second,
+			MaxConcurrency:   100,
+			MessageSize:      4096,
+			OperationsCount:  1000,
+			TestDuration:     30 * time.Second,
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(ServerClient),
+		}
+	case ServerClientPQ:
+		return ClientProfile{
+			MaxConcurrency:   100,
+			MessageSize:      4096,
+			OperationsCount:  1000,
+			TestDuration:     30 * time.Second,
+			Algorithm:        crypto.AlgorithmProfile{Algorithm: crypto.AlgorithmHybridPQ},
+			EncryptionMethod: defaultEncryptionMethod(ServerClientPQ),
 		}
 	default:
 		return GetClientProfile(DesktopClient)
 	}
 }
 
-// performCryptoOperation выполняет одну криптографическую операцию
-func performCryptoOperation(opID int, messageSize int, sharedSecret []byte, ecdsaPriv, ecdsaPub interface{}, rsaPriv, rsaPub interface{}) OperationResult {
+// performCryptoOperation выполняет одну криптографическую операцию:
+// шифрование/расшифровку sharedSecret-ом по выбранному method и
+// подпись/проверку сообщения ключевой парой keyPair, сгенерированной под
+// конкретную ячейку матрицы crypto.AlgorithmProfile через
+// crypto.GenerateKeyPair.
+func performCryptoOperation(opID int, messageSize int, sharedSecret []byte, method EncryptionMethod, streamingMode bool, chunkSize int, keyPair crypto.KeyPair, stats *metrics.SecurityStats) OperationResult {
 
 	result := OperationResult{
 		OperationID: opID,
@@ -121,46 +276,160 @@ func performCryptoOperation(opID int, messageSize int, sharedSecret []byte, ecds
 		result.Success = false
 		return result
 	}
-	// Создаем stats для операций
-	stats := metrics.NewSecurityStats()
 
-	// Генерируем IV для AES
-	iv := make([]byte, 16)
-	if _, err := rand.Read(iv); err != nil {
-		result.Error = fmt.Errorf("failed to generate IV: %v", err)
-		result.Success = false
-		return result
-	}
+	if streamingMode {
+		// Потоковый путь: фиксированными чанками через
+		// NewEncryptingWriterWithChunkSize/NewDecryptingReader вместо
+		// одноразового AESEncrypt/AEAD.Seal над всем буфером (см.
+		// PerformanceProfile.StreamingMode). Шифротекст здесь все же
+		// собирается целиком в bytes.Buffer, чтобы затем расшифровать его.
+		if chunkSize <= 0 {
+			chunkSize = crypto.StreamChunkSize
+		}
+		iv := make([]byte, 12)
+		if _, err := rand.Read(iv); err != nil {
+			result.Error = fmt.Errorf("failed to generate IV: %v", err)
+			result.Success = false
+			return result
+		}
 
-	// Замеряем время шифрования
-	encStart := time.Now()
-	encryptedData := crypto.AESEncrypt(sharedSecret[:32], iv, message, stats)
-	result.EncryptionTime = time.Since(encStart)
+		var encrypted bytes.Buffer
+		encStart := time.Now()
+		writer, err := crypto.NewEncryptingWriterWithChunkSize(&encrypted, sharedSecret[:32], iv, chunkSize, stats)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create encrypting writer: %v", err)
+			result.Success = false
+			return result
+		}
+		if _, err := writer.Write(message); err != nil {
+			result.Error = fmt.Errorf("stream encrypt failed: %v", err)
+			result.Success = false
+			return result
+		}
+		if err := writer.Close(); err != nil {
+			result.Error = fmt.Errorf("stream encrypt close failed: %v", err)
+			result.Success = false
+			return result
+		}
+		result.EncryptionTime = time.Since(encStart)
+
+		decStart := time.Now()
+		reader, err := crypto.NewDecryptingReader(bytes.NewReader(encrypted.Bytes()), sharedSecret[:32], iv, stats)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create decrypting reader: %v", err)
+			result.Success = false
+			return result
+		}
+		decryptedData, err := io.ReadAll(reader)
+		result.DecryptionTime = time.Since(decStart)
+		if err != nil {
+			result.Error = fmt.Errorf("stream decrypt failed: %v", err)
+			result.Success = false
+			return result
+		}
+		if len(decryptedData) != len(message) {
+			result.Error = fmt.Errorf("decrypted data length mismatch")
+			result.Success = false
+			return result
+		}
+	} else if method == EncryptionMethodPlain {
+		// Легаси-путь: AES-256-CBC без MAC. Шифротекст не аутентифицирован,
+		// поэтому целостность можно проверить лишь приблизительно —
+		// сравнением первого/последнего байта расшифровки с исходным
+		// сообщением (в отличие от AEAD-веток ниже, где Open сам
+		// отвергает подмененный шифротекст).
+		iv := make([]byte, 16)
+		if _, err := rand.Read(iv); err != nil {
+			result.Error = fmt.Errorf("failed to generate IV: %v", err)
+			result.Success = false
+			return result
+		}
 
-	// Замеряем время расшифровки
-	decStart := time.Now()
-	decryptedData, err := crypto.AESDecrypt(sharedSecret[:32], iv, encryptedData, stats)
-	result.DecryptionTime = time.Since(decStart)
+		encStart := time.Now()
+		encryptedData := crypto.AESEncrypt(sharedSecret[:32], iv, message, stats)
+		result.EncryptionTime = time.Since(encStart)
+
+		decStart := time.Now()
+		decryptedData, err := crypto.AESDecrypt(sharedSecret[:32], iv, encryptedData, stats)
+		result.DecryptionTime = time.Since(decStart)
+		if err != nil {
+			result.Error = fmt.Errorf("decryption failed: %v", err)
+			result.Success = false
+			return result
+		}
 
-	if err != nil {
-		result.Error = fmt.Errorf("decryption failed: %v", err)
-		result.Success = false
-		return result
+		if len(decryptedData) != len(message) {
+			result.Error = fmt.Errorf("decrypted data length mismatch")
+			result.Success = false
+			return result
+		}
+		if len(message) > 0 && (decryptedData[0] != message[0] ||
+			decryptedData[len(decryptedData)-1] != message[len(message)-1]) {
+			result.Error = fmt.Errorf("data integrity check failed")
+			result.Success = false
+			return result
+		}
+	} else {
+		aead, err := crypto.AEADBySuite(method.cipherSuite())
+		if err != nil {
+			result.Error = err
+			result.Success = false
+			return result
+		}
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			result.Error = fmt.Errorf("failed to generate nonce: %v", err)
+			result.Success = false
+			return result
+		}
+
+		encStart := time.Now()
+		encryptedData, err := aead.Seal(sharedSecret[:32], nonce, message, nil, stats)
+		result.EncryptionTime = time.Since(encStart)
+		if err != nil {
+			result.Error = fmt.Errorf("AEAD seal failed: %v", err)
+			result.Success = false
+			return result
+		}
+
+		// Open сам аутентифицирует шифротекст — в отличие от легаси-ветки
+		// выше, отдельная побайтовая проверка целостности не нужна: любая
+		// подмена encryptedData или nonce приводит к ошибке здесь.
+		decStart := time.Now()
+		decryptedData, err := aead.Open(sharedSecret[:32], nonce, encryptedData, nil, stats)
+		result.DecryptionTime = time.Since(decStart)
+		if err != nil {
+			result.Error = fmt.Errorf("AEAD authentication failed: %v", err)
+			result.Success = false
+			return result
+		}
+		if len(decryptedData) != len(message) {
+			result.Error = fmt.Errorf("decrypted data length mismatch")
+			result.Success = false
+			return result
+		}
 	}
 
-	// Проверяем корректность расшифровки
-	if len(decryptedData) != len(message) {
-		result.Error = fmt.Errorf("decrypted data length mismatch")
+	// Замеряем время подписи и проверки по ячейке матрицы keyPair.Algorithm
+	sigStart := time.Now()
+	signature, err := crypto.SignWithKeyPair(keyPair, message, stats)
+	result.SigningTime = time.Since(sigStart)
+	if err != nil {
+		result.Error = fmt.Errorf("signing failed: %v", err)
 		result.Success = false
 		return result
 	}
-	// Простая проверка целостности (сравнение первых и последних байт)
-	if len(message) > 0 && (decryptedData[0] != message[0] ||
-		decryptedData[len(decryptedData)-1] != message[len(message)-1]) {
-		result.Error = fmt.Errorf("data integrity check failed")
+
+	verStart := time.Now()
+	valid := crypto.VerifyWithKeyPair(keyPair, message, signature, stats)
+	result.VerificationTime = time.Since(verStart)
+	if !valid {
+		result.Error = fmt.Errorf("signature verification failed")
 		result.Success = false
 		return result
 	}
+
 	result.Success = true
 	return result
 }
@@ -168,20 +437,51 @@ func performCryptoOperation(opID int, messageSize int, sharedSecret []byte, ecds
 // RunLoadTest выполняет нагрузочный тест для указанного типа клиента
 func RunLoadTest(clientType ClientType) LoadTestResult {
 	profile := GetClientProfile(clientType)
-	return runLoadTestWithProfile(clientType, profile.MaxConcurrency, profile.MessageSize, profile.TestDuration)
+	return runLoadTestWithProfile(clientType, profile.MaxConcurrency, profile.MessageSize, profile.TestDuration, profile.Algorithm, profile.EncryptionMethod, profile.StreamingMode, profile.ChunkSize)
 }
 
-// RunLoadTestWithParams выполняет нагрузочный тест с пользовательскими параметрами
+// RunLoadTestWithParams выполняет нагрузочный тест с пользовательскими
+// параметрами, используя ячейку матрицы из GetClientProfile(clientType) и
+// AEAD-набор по умолчанию для clientType (см. defaultEncryptionMethod) — для
+// явного выбора набора шифров используйте RunLoadTestWithEncryption, для
+// потокового режима — RunLoadTestWithStreaming.
 func RunLoadTestWithParams(clientType ClientType, concurrentUsers int, messageSize int, testDuration time.Duration) LoadTestResult {
-	return runLoadTestWithProfile(clientType, concurrentUsers, messageSize, testDuration)
+	return runLoadTestWithProfile(clientType, concurrentUsers, messageSize, testDuration, GetClientProfile(clientType).Algorithm, defaultEncryptionMethod(clientType), false, 0)
+}
+
+// RunLoadTestWithAlgorithm выполняет нагрузочный тест для указанного типа
+// клиента и конкретной ячейки матрицы асимметричных примитивов algo (см.
+// crypto.AlgorithmProfiles) — используется RunAlgorithmMatrixLoadTests для
+// перебора всей матрицы (ClientType, Algorithm).
+func RunLoadTestWithAlgorithm(clientType ClientType, algo crypto.AlgorithmProfile) LoadTestResult {
+	profile := GetClientProfile(clientType)
+	return runLoadTestWithProfile(clientType, profile.MaxConcurrency, profile.MessageSize, profile.TestDuration, algo, profile.EncryptionMethod, profile.StreamingMode, profile.ChunkSize)
+}
+
+// RunLoadTestWithEncryption выполняет нагрузочный тест с пользовательскими
+// параметрами и явно заданным method — используется при сравнении наборов
+// шифров на одном и том же типе клиента.
+func RunLoadTestWithEncryption(clientType ClientType, concurrentUsers int, messageSize int, testDuration time.Duration, method EncryptionMethod) LoadTestResult {
+	return runLoadTestWithProfile(clientType, concurrentUsers, messageSize, testDuration, defaultAlgorithmProfile(), method, false, 0)
+}
+
+// RunLoadTestWithStreaming выполняет нагрузочный тест в потоковом режиме
+// (см. PerformanceProfile.StreamingMode) с заданным chunkSize — используется
+// для сравнения потокового API с одноразовым AESEncrypt/AEAD.Seal на больших
+// размерах сообщений (см. BenchmarkDifferentMessageSizes).
+func RunLoadTestWithStreaming(clientType ClientType, concurrentUsers int, messageSize int, testDuration time.Duration, chunkSize int) LoadTestResult {
+	return runLoadTestWithProfile(clientType, concurrentUsers, messageSize, testDuration, defaultAlgorithmProfile(), defaultEncryptionMethod(clientType), true, chunkSize)
 }
 
 // runLoadTestWithProfile внутренняя функция для выполнения нагрузочного теста
-func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageSize int, testDuration time.Duration) LoadTestResult {
+func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageSize int, testDuration time.Duration, algo crypto.AlgorithmProfile, method EncryptionMethod, streamingMode bool, chunkSize int) LoadTestResult {
+	if algo.Algorithm == "" {
+		algo = defaultAlgorithmProfile()
+	}
 	// Вычисляем количество операций на основе длительности теста
 	operationsCount := concurrentUsers * 10 // примерно 10 операций на пользователя
 
-	fmt.Printf("Запуск нагрузочного теста для %s клиента...\n", clientType)
+	fmt.Printf("Запуск нагрузочного теста для %s клиента (%s, %s)...\n", clientType, algo, method)
 	fmt.Printf("Параметры: %d пользователей, %d операций, размер сообщения: %d байт\n",
 		concurrentUsers, operationsCount, messageSize)
 
@@ -192,12 +492,19 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 
 	start := time.Now()
 
+	// Реальное сэмплирование CPU и пика горутин вместо фиксированной формулы
+	// (см. cpuSampler) — снимается на протяжении всего теста.
+	sampler := startCPUSampling()
+
 	// Каналы для сбора результатов
 	results := make(chan OperationResult, operationsCount)
 
 	// Подготавливаем общие данные
-	ecdsaPriv, ecdsaPub := crypto.GenerateECDHKeys()
-	rsaPriv, rsaPub := crypto.GenerateRSAKeys()
+	stats := metrics.NewSecurityStats()
+	keyPair, err := crypto.GenerateKeyPair(algo)
+	if err != nil {
+		log.Printf("не удалось сгенерировать ключевую пару для %s: %v", algo, err)
+	}
 	sharedSecret := make([]byte, 64)
 	rand.Read(sharedSecret)
 
@@ -217,8 +524,7 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			result := performCryptoOperation(opID, messageSize, sharedSecret,
-				ecdsaPriv, ecdsaPub, rsaPriv, rsaPub)
+			result := performCryptoOperation(opID, messageSize, sharedSecret, method, streamingMode, chunkSize, keyPair, stats)
 			results <- result
 		}(i)
 	}
@@ -235,13 +541,19 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 		failedOps     = 0
 		totalEncTime  = 0.0
 		totalDecTime  = 0.0
+		totalSigTime  = 0.0
+		totalVerTime  = 0.0
+		operations    = make([]OperationResult, 0, operationsCount)
 	)
 
 	for result := range results {
+		operations = append(operations, result)
 		if result.Success {
 			successfulOps++
 			totalEncTime += float64(result.EncryptionTime.Milliseconds())
 			totalDecTime += float64(result.DecryptionTime.Milliseconds())
+			totalSigTime += float64(result.SigningTime.Milliseconds())
+			totalVerTime += float64(result.VerificationTime.Milliseconds())
 		} else {
 			failedOps++
 			fmt.Printf("Операция %d завершилась с ошибкой: %v\n", result.OperationID, result.Error)
@@ -249,26 +561,35 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 	}
 
 	elapsed := time.Since(start)
+	cpuMean, cpuP95, goroutinesPeak := sampler.Stop()
 
 	// Финальные метрики памяти
 	var endMemStats runtime.MemStats
 	runtime.GC()
 	runtime.ReadMemStats(&endMemStats)
+	gcPauseMs := float64(endMemStats.PauseTotalNs-startMemStats.PauseTotalNs) / 1e6
 
 	// Вычисляем метрики
 	avgEncTime := 0.0
 	avgDecTime := 0.0
+	avgSigTime := 0.0
+	avgVerTime := 0.0
 	if successfulOps > 0 {
 		avgEncTime = totalEncTime / float64(successfulOps)
 		avgDecTime = totalDecTime / float64(successfulOps)
+		avgSigTime = totalSigTime / float64(successfulOps)
+		avgVerTime = totalVerTime / float64(successfulOps)
 	}
 
 	throughput := float64(successfulOps) / elapsed.Seconds()
+	throughputMBPerSec := throughput * float64(messageSize) / (1024 * 1024)
 	errorRate := float64(failedOps) / float64(operationsCount) * 100.0
 	memoryUsageMB := float64(endMemStats.Alloc-startMemStats.Alloc) / 1024 / 1024
 
-	// Примерная оценка использования CPU (упрощенная)
-	cpuUsage := calculateCPUUsage(elapsed, concurrentUsers)
+	encDurations := operationDurationsMs(operations, func(op OperationResult) time.Duration { return op.EncryptionTime })
+	decDurations := operationDurationsMs(operations, func(op OperationResult) time.Duration { return op.DecryptionTime })
+	sort.Float64s(encDurations)
+	sort.Float64s(decDurations)
 
 	result := LoadTestResult{
 		ClientType:          clientType,
@@ -278,11 +599,28 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 		FailedOps:           failedOps,
 		AvgEncryptionTime:   avgEncTime,
 		AvgDecryptionTime:   avgDecTime,
+		AvgSigningTime:      avgSigTime,
+		AvgVerificationTime: avgVerTime,
+		EncP50Ms:            percentile(encDurations, 50),
+		EncP95Ms:            percentile(encDurations, 95),
+		EncP99Ms:            percentile(encDurations, 99),
+		EncMaxMs:            maxOf(encDurations),
+		DecP50Ms:            percentile(decDurations, 50),
+		DecP95Ms:            percentile(decDurations, 95),
+		DecP99Ms:            percentile(decDurations, 99),
+		DecMaxMs:            maxOf(decDurations),
+		Algorithm:           algo.String(),
+		EncryptionMethod:    method.String(),
 		ThroughputOpsPerSec: throughput,
+		ThroughputMBPerSec:  throughputMBPerSec,
 		TestDuration:        elapsed.Milliseconds(),
 		MemoryUsageMB:       memoryUsageMB,
-		CPUUsagePercent:     cpuUsage,
+		CPUUsagePercent:     cpuMean,
+		CPUUsageP95:         cpuP95,
+		GoroutinesPeak:      goroutinesPeak,
+		GCPauseMs:           gcPauseMs,
 		ErrorRate:           errorRate,
+		Operations:          operations,
 	}
 	// Выводим результаты
 	printLoadTestResults(result)
@@ -290,38 +628,122 @@ func runLoadTestWithProfile(clientType ClientType, concurrentUsers int, messageS
 	return result
 }
 
-// calculateCPUUsage вычисляет примерное использование CPU
-func calculateCPUUsage(duration time.Duration, concurrency int) float64 {
-	// Упрощенная формула: больше concurrent операций = больше CPU
-	// В реальном приложении следует использовать более точные метрики
-	baseUsage := 10.0                                       // базовое использование
-	concurrencyFactor := float64(concurrency) / 10.0 * 15.0 // масштабирование по concurrency
-
-	if concurrencyFactor > 80.0 {
-		concurrencyFactor = 80.0 // ограничиваем максимум
+// percentile возвращает значение перцентиля p (0..100) отсортированного по
+// возрастанию среза sorted — тот же способ вычисления индекса, которым
+// cpuSampler.Stop уже считает CPUUsageP95.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
 	}
+	idx := int(float64(len(sorted)) * p / 100)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
 
-	return baseUsage + concurrencyFactor
+// maxOf возвращает наибольшее значение отсортированного по возрастанию sorted.
+func maxOf(sorted []float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[len(sorted)-1]
 }
 
 // printLoadTestResults выводит результаты тестирования в консоль
 func printLoadTestResults(result LoadTestResult) {
 	fmt.Printf("\n=== Результаты нагрузочного тестирования ===\n")
 	fmt.Printf("Тип клиента: %s\n", result.ClientType)
+	fmt.Printf("Алгоритм: %s\n", result.Algorithm)
+	fmt.Printf("Набор шифров: %s\n", result.EncryptionMethod)
 	fmt.Printf("Общее количество операций: %d\n", result.TotalOperations)
 	fmt.Printf("Успешных операций: %d\n", result.SuccessfulOps)
 	fmt.Printf("Неуспешных операций: %d\n", result.FailedOps)
 	fmt.Printf("Процент ошибок: %.2f%%\n", result.ErrorRate)
 	fmt.Printf("Среднее время шифрования: %.2f мс\n", result.AvgEncryptionTime)
 	fmt.Printf("Среднее время расшифровки: %.2f мс\n", result.AvgDecryptionTime)
-	fmt.Printf("Пропускная способность: %.2f оп/сек\n", result.ThroughputOpsPerSec)
+	fmt.Printf("Среднее время подписи: %.2f мс\n", result.AvgSigningTime)
+	fmt.Printf("Среднее время проверки подписи: %.2f мс\n", result.AvgVerificationTime)
+	fmt.Printf("Латентность шифрования (p50/p95/p99/max): %.2f / %.2f / %.2f / %.2f мс\n",
+		result.EncP50Ms, result.EncP95Ms, result.EncP99Ms, result.EncMaxMs)
+	fmt.Printf("Латентность расшифровки (p50/p95/p99/max): %.2f / %.2f / %.2f / %.2f мс\n",
+		result.DecP50Ms, result.DecP95Ms, result.DecP99Ms, result.DecMaxMs)
+	fmt.Printf("Пропускная способность: %.2f оп/сек (%.2f МБ/сек)\n", result.ThroughputOpsPerSec, result.ThroughputMBPerSec)
 	fmt.Printf("Продолжительность теста: %d мс\n", result.TestDuration)
 	fmt.Printf("Использование памяти: %.2f МБ\n", result.MemoryUsageMB)
-	fmt.Printf("Использование CPU: %.2f%%\n", result.CPUUsagePercent)
+	fmt.Printf("Использование CPU (среднее/p95): %.2f%% / %.2f%%\n", result.CPUUsagePercent, result.CPUUsageP95)
+	fmt.Printf("Пик горутин: %d\n", result.GoroutinesPeak)
+	fmt.Printf("Суммарная пауза GC: %.2f мс\n", result.GCPauseMs)
 	fmt.Printf("Количество concurrent пользователей: %d\n", result.ConcurrentUsers)
 	fmt.Printf("============================================\n\n")
 }
 
+// MatrixKey идентифицирует одну ячейку матрицы нагрузочных тестов —
+// комбинацию типа клиента и ячейки AlgorithmProfile (см.
+// RunAlgorithmMatrixLoadTests/CompareAlgorithmMatrixPerformance).
+type MatrixKey struct {
+	ClientType ClientType
+	Algorithm  string
+}
+
+// RunAlgorithmMatrixLoadTests прогоняет нагрузочный тест для каждого типа
+// клиента по всей матрице crypto.AlgorithmProfiles (RSA-1024/2048/3072/4096,
+// ECDSA P256/P384/P521, Ed25519) — расширение RunAllClientLoadTests, в
+// котором зафиксированная пара ECDH+RSA была единственной ячейкой.
+func RunAlgorithmMatrixLoadTests() map[MatrixKey]LoadTestResult {
+	clientTypes := []ClientType{MobileClient, WebClient, DesktopClient, ServerClient}
+	algoProfiles := crypto.AlgorithmProfiles()
+	results := make(map[MatrixKey]LoadTestResult, len(clientTypes)*len(algoProfiles))
+
+	fmt.Println("Запуск матрицы нагрузочных тестов (клиент x алгоритм)...")
+
+	for _, clientType := range clientTypes {
+		for _, algo := range algoProfiles {
+			key := MatrixKey{ClientType: clientType, Algorithm: algo.String()}
+			fmt.Printf("\n--- %s / %s ---\n", clientType, algo)
+			results[key] = RunLoadTestWithAlgorithm(clientType, algo)
+		}
+	}
+
+	return results
+}
+
+// CompareAlgorithmMatrixPerformance сравнивает и ранжирует результаты матрицы
+// (ClientType, Algorithm) по EfficiencyScore — аналог CompareClientPerformance,
+// но по ячейкам матрицы вместо одних лишь типов клиентов.
+func CompareAlgorithmMatrixPerformance(results map[MatrixKey]LoadTestResult) {
+	fmt.Println("\n=== СРАВНЕНИЕ ПРОИЗВОДИТЕЛЬНОСТИ ПО МАТРИЦЕ (КЛИЕНТ x АЛГОРИТМ) ===")
+
+	type matrixResult struct {
+		Key    MatrixKey
+		Result LoadTestResult
+	}
+
+	var sortedResults []matrixResult
+	for key, result := range results {
+		sortedResults = append(sortedResults, matrixResult{Key: key, Result: result})
+	}
+
+	sort.Slice(sortedResults, func(i, j int) bool {
+		return sortedResults[i].Result.EfficiencyScore > sortedResults[j].Result.EfficiencyScore
+	})
+
+	fmt.Printf("%-15s %-15s %-12s %-15s %-15s %-10s\n",
+		"Клиент", "Алгоритм", "Операций/сек", "Подпись (мс)", "Проверка (мс)", "Ошибки %")
+	fmt.Println(strings.Repeat("-", 90))
+
+	for i, mr := range sortedResults {
+		fmt.Printf("%d. %-12s %-15s %-12.2f %-15.4f %-15.4f %-10.2f\n",
+			i+1,
+			mr.Key.ClientType,
+			mr.Key.Algorithm,
+			mr.Result.ThroughputOpsPerSec,
+			mr.Result.AvgSigningTime,
+			mr.Result.AvgVerificationTime,
+			mr.Result.ErrorRate)
+	}
+}
+
 // RunAllClientLoadTests запускает нагрузочные тесты для всех типов клиентов
 func RunAllClientLoadTests() map[ClientType]LoadTestResult {
 	clientTypes := []ClientType{MobileClient, WebClient, DesktopClient, ServerClient}
@@ -385,18 +807,20 @@ func CompareClientPerformance(results map[ClientType]LoadTestResult) {
 		return sortedResults[i].Result.EfficiencyScore > sortedResults[j].Result.EfficiencyScore
 	})
 
-	fmt.Printf("%-15s %-12s %-15s %-12s %-10s %-15s\n",
-		"Клиент", "Операций/сек", "Время шифр. (мс)", "Время расшифр.", "Ошибки %", "Эффективность")
-	fmt.Println(strings.Repeat("-", 90))
+	fmt.Printf("%-15s %-12s %-15s %-12s %-10s %-10s %-10s %-15s\n",
+		"Клиент", "Операций/сек", "Время шифр. (мс)", "Время расшифр.", "p99 шифр.", "p99 расш.", "Ошибки %", "Эффективность")
+	fmt.Println(strings.Repeat("-", 110))
 
 	for i, cr := range sortedResults {
 		rank := i + 1
-		fmt.Printf("%d. %-12s %-12.2f %-15.2f %-12.2f %-10.2f %-15.4f\n",
+		fmt.Printf("%d. %-12s %-12.2f %-15.2f %-12.2f %-10.2f %-10.2f %-10.2f %-15.4f\n",
 			rank,
 			cr.ClientType,
 			cr.Result.ThroughputOpsPerSec,
 			cr.Result.AvgEncryptionTime,
 			cr.Result.AvgDecryptionTime,
+			cr.Result.EncP99Ms,
+			cr.Result.DecP99Ms,
 			cr.Result.ErrorRate,
 			cr.Result.EfficiencyScore)
 	}
@@ -433,32 +857,49 @@ func CompareClientPerformance(results map[ClientType]LoadTestResult) {
 func GetDefaultProfiles() []PerformanceProfile {
 	return []PerformanceProfile{
 		{
-			ClientType:      MobileClient,
-			MessageSize:     1024, // 1KB - типично для мобильных устройств
-			ConcurrentUsers: 10,   // Меньше пользователей
-			TestDuration:    30 * time.Second,
-			Description:     "Мобильное устройство: малые сообщения, низкая нагрузка",
+			ClientType:       MobileClient,
+			MessageSize:      1024, // 1KB - типично для мобильных устройств
+			ConcurrentUsers:  10,   // Меньше пользователей
+			TestDuration:     30 * time.Second,
+			Description:      "Мобильное устройство: малые сообщения, низкая нагрузка",
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(MobileClient),
+		},
+		{
+			ClientType:       WebClient,
+			MessageSize:      4096, // 4KB - веб-формы и JSON
+			ConcurrentUsers:  25,   // Средняя нагрузка
+			TestDuration:     30 * time.Second,
+			Description:      "Веб-клиент: средние сообщения, умеренная нагрузка",
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(WebClient),
 		},
 		{
-			ClientType:      WebClient,
-			MessageSize:     4096, // 4KB - веб-формы и JSON
-			ConcurrentUsers: 25,   // Средняя нагрузка
-			TestDuration:    30 * time.Second,
-			Description:     "Веб-клиент: средние сообщения, умеренная нагрузка",
+			ClientType:       DesktopClient,
+			MessageSize:      8192, // 8KB - файлы и документы
+			ConcurrentUsers:  50,   // Высокая нагрузка
+			TestDuration:     30 * time.Second,
+			Description:      "Десктопное приложение: большие сообщения, высокая нагрузка",
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(DesktopClient),
 		},
 		{
-			ClientType:      DesktopClient,
-			MessageSize:     8192, // 8KB - файлы и документы
-			ConcurrentUsers: 50,   // Высокая нагрузка
-			TestDuration:    30 * time.Second,
-			Description:     "Десктопное приложение: большие сообщения, высокая нагрузка",
+			ClientType:       ServerClient,
+			MessageSize:      16384, // 16KB - серверные данные
+			ConcurrentUsers:  100,   // Максимальная нагрузка
+			TestDuration:     30 * time.Second,
+			Description:      "Сервер: крупные сообщения, максимальная нагрузка",
+			Algorithm:        defaultAlgorithmProfile(),
+			EncryptionMethod: defaultEncryptionMethod(ServerClient),
 		},
 		{
-			ClientType:      ServerClient,
-			MessageSize:     16384, // 16KB - серверные данные
-			ConcurrentUsers: 100,   // Максимальная нагрузка
-			TestDuration:    30 * time.Second,
-			Description:     "Сервер: крупные сообщения, максимальная нагрузка",
+			ClientType:       ServerClientPQ,
+			MessageSize:      16384, // 16KB - серверные данные
+			ConcurrentUsers:  100,   // Максимальная нагрузка
+			TestDuration:     30 * time.Second,
+			Description:      "Сервер (PQ): тот же профиль, что Server, но с гибридной подписью Dilithium3+Ed25519 вместо ECDSA",
+			Algorithm:        crypto.AlgorithmProfile{Algorithm: crypto.AlgorithmHybridPQ},
+			EncryptionMethod: defaultEncryptionMethod(ServerClientPQ),
 		},
 	}
 }
@@ -479,10 +920,13 @@ func RunComprehensiveLoadTests() map[ClientType]LoadTestResult {
 
 		result := RunLoadTestWithParams(profile.ClientType, profile.ConcurrentUsers, profile.MessageSize, profile.TestDuration)
 		results[profile.ClientType] = result
-		// Добавляем расчет показателя эффективности с учетом специфики устройства
+		// Добавляем расчет показателя эффективности с учетом специфики устройства.
+		// Используем p99, а не среднее: средняя латентность нечувствительна к
+		// редким, но ощутимым для пользователя задержкам (например, паузам GC),
+		// которые как раз и показывает хвост распределения.
 		stats := metrics.NewSecurityStats()
-		stats.RecordEncryptionTime(time.Duration(result.AvgEncryptionTime * float64(time.Millisecond)))
-		stats.RecordDecryptionTime(time.Duration(result.AvgDecryptionTime * float64(time.Millisecond)))
+		stats.RecordEncryptionTime(time.Duration(result.EncP99Ms * float64(time.Millisecond)))
+		stats.RecordDecryptionTime(time.Duration(result.DecP99Ms * float64(time.Millisecond)))
 
 		// Настраиваем параметры в зависимости от типа клиента
 		switch profile.ClientType {
@@ -13,6 +13,30 @@ type SecurityStats struct {
 	KeyLength         int
 	AttackProbability float64
 	LastCheckTime     time.Time
+	RekeyCount        int // Количество выполненных ротаций ключей сессии
+
+	// CipherSuiteCounts — количество операций шифрования/расшифровки,
+	// выполненных под каждым согласованным набором шифров SecureMessage
+	// (ключ — CipherSuite.String(), например "AES-256-CBC+HMAC-SHA256").
+	CipherSuiteCounts map[string]int
+
+	// RateLimitAllowed/RateLimitRejected — глобальные счетчики решений
+	// crypto.RateLimiter.Allow за все время жизни stats, не привязанные ни к
+	// какому конкретному клиенту.
+	RateLimitAllowed  int
+	RateLimitRejected int
+	// RateLimitRejectedByClient — количество отказов Allow по каждому
+	// clientID, по аналогии с CipherSuiteCounts.
+	RateLimitRejectedByClient map[string]int
+
+	// ConcurrencyAllowed/ConcurrencyRejected — глобальные счетчики решений
+	// crypto.AdaptiveConcurrencyLimiter.Allow.
+	ConcurrencyAllowed  int
+	ConcurrencyRejected int
+	// LastConcurrencyLimit — лимит параллельности (CurrentLimit), который
+	// действовал в момент последнего решения Allow, для наблюдения за тем,
+	// как лимитер подстраивается во времени.
+	LastConcurrencyLimit int64
 }
 
 // Максимальные значения для нормализации согласно заданию
@@ -28,6 +52,7 @@ func NewSecurityStats() *SecurityStats {
 		LastCheckTime:     time.Now(),
 		KeyLength:         256,    // По умолчанию AES-256
 		AttackProbability: 0.0001, // Очень низкая вероятность для AES-256
+		CipherSuiteCounts: make(map[string]int),
 	}
 }
 
@@ -55,6 +80,20 @@ func (stats *SecurityStats) SetAttackProbability(probability float64) {
 	stats.AttackProbability = probability
 }
 
+// RecordRekey увеличивает счетчик ротаций ключей сессии.
+func (stats *SecurityStats) RecordRekey() {
+	stats.RekeyCount++
+}
+
+// RecordCipherSuiteUsage увеличивает счетчик измерений, произведенных под
+// указанным набором шифров (см. CipherSuiteBreakdown).
+func (stats *SecurityStats) RecordCipherSuiteUsage(suite string) {
+	if stats.CipherSuiteCounts == nil {
+		stats.CipherSuiteCounts = make(map[string]int)
+	}
+	stats.CipherSuiteCounts[suite]++
+}
+
 func (stats *SecurityStats) PrintStats() {
 	fmt.Printf("Статистика безопасности (с момента последней проверки %v):\n", time.Since(stats.LastCheckTime))
 	fmt.Printf("  Время шифрования: %d мс\n", stats.EncryptionTime.Milliseconds())
@@ -158,4 +197,66 @@ func (stats *SecurityStats) PrintDetailedReport() {
 	fmt.Printf("\nИНТЕГРАЛЬНЫЙ ПОКАЗАТЕЛЬ ЭФФЕКТИВНОСТИ: %.4f\n", efficiency)
 	fmt.Printf("Диапазон: [0, 1], где 1 - максимальная эффективность\n")
 	fmt.Println("==========================================")
+
+	stats.CipherSuiteBreakdown()
+}
+
+// RecordRateLimitDecision учитывает одно решение crypto.RateLimiter.Allow:
+// увеличивает глобальный счетчик (RateLimitAllowed/RateLimitRejected) и, при
+// отказе, счетчик конкретного clientID в RateLimitRejectedByClient.
+func (stats *SecurityStats) RecordRateLimitDecision(clientID string, allowed bool) {
+	if allowed {
+		stats.RateLimitAllowed++
+		return
+	}
+	stats.RateLimitRejected++
+	if stats.RateLimitRejectedByClient == nil {
+		stats.RateLimitRejectedByClient = make(map[string]int)
+	}
+	stats.RateLimitRejectedByClient[clientID]++
+}
+
+// RateLimitBreakdown выводит глобальные счетчики решений RateLimiter.Allow и
+// распределение отказов по клиентам.
+func (stats *SecurityStats) RateLimitBreakdown() {
+	fmt.Println("\n=== РЕШЕНИЯ RATE LIMITER ===")
+	fmt.Printf("  Разрешено: %d, отклонено: %d\n", stats.RateLimitAllowed, stats.RateLimitRejected)
+	for clientID, count := range stats.RateLimitRejectedByClient {
+		fmt.Printf("  %s: %d отказов\n", clientID, count)
+	}
+	fmt.Println("==========================================")
+}
+
+// RecordConcurrencyDecision учитывает одно решение
+// crypto.AdaptiveConcurrencyLimiter.Allow и текущий лимит параллельности,
+// действовавший в момент принятия этого решения.
+func (stats *SecurityStats) RecordConcurrencyDecision(allowed bool, currentLimit int64) {
+	if allowed {
+		stats.ConcurrencyAllowed++
+	} else {
+		stats.ConcurrencyRejected++
+	}
+	stats.LastConcurrencyLimit = currentLimit
+}
+
+// ConcurrencyBreakdown выводит глобальные счетчики решений
+// AdaptiveConcurrencyLimiter.Allow и последний наблюдаемый лимит.
+func (stats *SecurityStats) ConcurrencyBreakdown() {
+	fmt.Println("\n=== АДАПТИВНЫЙ ЛИМИТ ПАРАЛЛЕЛЬНОСТИ ===")
+	fmt.Printf("  Разрешено: %d, отклонено: %d, текущий лимит: %d\n",
+		stats.ConcurrencyAllowed, stats.ConcurrencyRejected, stats.LastConcurrencyLimit)
+	fmt.Println("==========================================")
+}
+
+// CipherSuiteBreakdown выводит количество операций шифрования/расшифровки,
+// выполненных под каждым согласованным набором шифров SecureMessage.
+func (stats *SecurityStats) CipherSuiteBreakdown() {
+	fmt.Println("\n=== РАСПРЕДЕЛЕНИЕ ПО НАБОРАМ ШИФРОВ ===")
+	if len(stats.CipherSuiteCounts) == 0 {
+		fmt.Println("  Нет данных")
+	}
+	for suite, count := range stats.CipherSuiteCounts {
+		fmt.Printf("  %s: %d операций\n", suite, count)
+	}
+	fmt.Println("==========================================")
 }
@@ -0,0 +1,220 @@
+// Package timing предоставляет статистически обоснованный детектор утечек по
+// времени выполнения (timing leak) на основе двухвыборочного t-теста Уэлча,
+// заменяющий эвристику "разница средних > 3 стандартных отклонений".
+package timing
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Result — результат сравнения двух выборок длительностей операций.
+type Result struct {
+	Mean1      float64 // Среднее первой выборки (нс)
+	Mean2      float64 // Среднее второй выборки (нс)
+	T          float64 // Статистика t Уэлча
+	DF         float64 // Степени свободы Уэлча-Саттерсвейта
+	PValue     float64 // Двусторонний p-value
+	CohensD    float64 // Величина эффекта (Cohen's d), sign совпадает с Mean1-Mean2
+	Leak       bool    // true, если p < LeakPValueThreshold
+	N1, N2     int     // Размеры выборок после отсечения выбросов
+}
+
+// LeakPValueThreshold — порог значимости, ниже которого разница во времени
+// считается статистически детектируемой утечкой по времени.
+const LeakPValueThreshold = 0.001
+
+// trimFraction — доля значений, отсекаемых с каждого края отсортированной
+// выборки для устранения выбросов планировщика ОС и пауз сборщика мусора.
+const trimFraction = 0.05
+
+// WelchTTest выполняет двухвыборочный t-тест Уэлча над двумя выборками
+// длительностей, предварительно отсекая по 5% самых быстрых и самых
+// медленных измерений в каждой выборке. Возвращает статистику t, степени
+// свободы Уэлча-Саттерсвейта, двусторонний p-value и величину эффекта
+// (Cohen's d), позволяющую отличить статистически значимую, но ничтожную
+// по величине разницу от практически эксплуатируемой.
+func WelchTTest(sample1, sample2 []time.Duration) Result {
+	x1 := trim(sample1)
+	x2 := trim(sample2)
+
+	n1, n2 := len(x1), len(x2)
+	mean1, var1 := meanVariance(x1)
+	mean2, var2 := meanVariance(x2)
+
+	se1 := var1 / float64(n1)
+	se2 := var2 / float64(n2)
+	standardError := math.Sqrt(se1 + se2)
+
+	var t float64
+	if standardError > 0 {
+		t = (mean1 - mean2) / standardError
+	}
+
+	df := welchSatterthwaiteDF(se1, se2, n1, n2)
+	p := twoSidedPValue(t, df)
+
+	pooledStdDev := math.Sqrt((var1 + var2) / 2)
+	var cohensD float64
+	if pooledStdDev > 0 {
+		cohensD = (mean1 - mean2) / pooledStdDev
+	}
+
+	return Result{
+		Mean1:   mean1,
+		Mean2:   mean2,
+		T:       t,
+		DF:      df,
+		PValue:  p,
+		CohensD: cohensD,
+		Leak:    p < LeakPValueThreshold,
+		N1:      n1,
+		N2:      n2,
+	}
+}
+
+// trim сортирует выборку и отбрасывает по trimFraction долю значений с
+// каждого края, чтобы убрать GC/scheduler-выбросы перед статистическим анализом.
+func trim(durations []time.Duration) []float64 {
+	if len(durations) == 0 {
+		return nil
+	}
+	sorted := make([]float64, len(durations))
+	for i, d := range durations {
+		sorted[i] = float64(d)
+	}
+	sort.Float64s(sorted)
+
+	cut := int(float64(len(sorted)) * trimFraction)
+	trimmed := sorted[cut : len(sorted)-cut]
+	if len(trimmed) < 2 {
+		return sorted
+	}
+	return trimmed
+}
+
+// meanVariance вычисляет выборочное среднее и несмещенную (n-1) дисперсию.
+func meanVariance(x []float64) (mean, variance float64) {
+	n := len(x)
+	if n == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	mean = sum / float64(n)
+
+	if n < 2 {
+		return mean, 0
+	}
+	var sumSq float64
+	for _, v := range x {
+		diff := v - mean
+		sumSq += diff * diff
+	}
+	variance = sumSq / float64(n-1)
+	return mean, variance
+}
+
+// welchSatterthwaiteDF вычисляет степени свободы по формуле Уэлча-Саттерсвейта:
+// df = (s1²/n1 + s2²/n2)² / ((s1²/n1)²/(n1-1) + (s2²/n2)²/(n2-1))
+func welchSatterthwaiteDF(se1, se2 float64, n1, n2 int) float64 {
+	numerator := (se1 + se2) * (se1 + se2)
+	denominator := (se1*se1)/float64(n1-1) + (se2*se2)/float64(n2-1)
+	if denominator == 0 {
+		return float64(n1 + n2 - 2)
+	}
+	return numerator / denominator
+}
+
+// twoSidedPValue вычисляет двусторонний p-value для статистики t-распределения
+// Стьюдента с df степенями свободы через регуляризованную неполную бета-функцию:
+// p = I_{df/(df+t²)}(df/2, 1/2).
+func twoSidedPValue(t, df float64) float64 {
+	if df <= 0 {
+		return 1
+	}
+	x := df / (df + t*t)
+	return regularizedIncompleteBeta(x, df/2, 0.5)
+}
+
+// regularizedIncompleteBeta вычисляет регуляризованную неполную бета-функцию
+// I_x(a, b) через непрерывную дробь Лентца (см. Numerical Recipes, 6.4).
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lnBeta := lgamma(a+b) - lgamma(a) - lgamma(b) +
+		a*math.Log(x) + b*math.Log(1-x)
+	front := math.Exp(lnBeta)
+
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+func lgamma(x float64) float64 {
+	v, _ := math.Lgamma(x)
+	return v
+}
+
+// betaContinuedFraction вычисляет непрерывную дробь, используемую в
+// regularizedIncompleteBeta, методом Лентца с модификацией.
+func betaContinuedFraction(x, a, b float64) float64 {
+	const maxIterations = 200
+	const epsilon = 3e-14
+	const tiny = 1e-300
+
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		m2 := float64(2 * m)
+
+		aa := float64(m) * (b - float64(m)) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + float64(m)) * (qab + float64(m)) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -22,7 +22,11 @@ func main() {
 			runAttackTests()
 			return
 		case "--load":
-			runLoadTests()
+			pushGatewayURL := ""
+			if len(os.Args) > 2 && strings.HasPrefix(os.Args[2], "--push-gateway=") {
+				pushGatewayURL = strings.TrimPrefix(os.Args[2], "--push-gateway=")
+			}
+			runLoadTests(pushGatewayURL)
 			return
 		case "--efficiency":
 			demonstrateEfficiencyCalculation()
@@ -40,6 +44,7 @@ func printUsage() {
 	fmt.Println("Использование:")
 	fmt.Println("  go run try_tests.go --attacks       - Тесты атак")
 	fmt.Println("  go run try_tests.go --load          - Нагрузочные тесты")
+	fmt.Println("  go run try_tests.go --load --push-gateway=<url> - Нагрузочные тесты с отправкой метрик в Prometheus Pushgateway")
 	fmt.Println("  go run try_tests.go --efficiency    - Демонстрация расчета эффективности")
 }
 
@@ -50,7 +55,7 @@ func runAttackTests() {
 	attack_tests.AnalyzeAttackResults(results)
 }
 
-func runLoadTests() {
+func runLoadTests(pushGatewayURL string) {
 	fmt.Println("\n=== НАГРУЗОЧНЫЕ ТЕСТЫ ===")
 
 	results := benchmark.RunComprehensiveLoadTests()
@@ -63,6 +68,27 @@ func runLoadTests() {
 	} else {
 		fmt.Println("✓ Результаты сохранены в load_test_results.json")
 	}
+
+	fmt.Println("Экспорт результатов в CSV...")
+	if err := benchmark.ExportResultsCSV(results, "load_test_results.csv"); err != nil {
+		fmt.Printf("Ошибка экспорта CSV: %v\n", err)
+	} else {
+		fmt.Println("✓ Результаты сохранены в load_test_results.csv")
+	}
+
+	fmt.Println("Экспорт результатов в OpenMetrics...")
+	if err := benchmark.ExportResultsPrometheus(results, "load_test_results.prom"); err != nil {
+		fmt.Printf("Ошибка экспорта OpenMetrics: %v\n", err)
+	} else {
+		fmt.Println("✓ Результаты сохранены в load_test_results.prom")
+	}
+
+	if pushGatewayURL != "" {
+		fmt.Printf("Отправка результатов в pushgateway %s...\n", pushGatewayURL)
+		if err := benchmark.PushResultsToGateway(pushGatewayURL, results); err != nil {
+			fmt.Printf("Ошибка отправки в pushgateway: %v\n", err)
+		}
+	}
 }
 
 func demonstrateEfficiencyCalculation() {
@@ -4,11 +4,93 @@ import (
 	"bytes"
 	"client-server/internal/crypto"
 	"client-server/tests/metrics"
+	"context"
 	"crypto/rand"
+	"fmt"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
+// nonceTrackerBackend описывает один backend crypto.NonceTracker для
+// табличного прогона testReplayAttackPrevention/testConcurrentAccess.
+// setup возвращает два "инстанса" трекера (a, b) — для memory это
+// действительно независимые трекеры (демонстрирует исходную проблему,
+// описанную в chunk3-5: два процесса с собственной памятью не видят replay
+// друг друга); для bbolt — один и тот же трекер, разделяющий один файл в
+// пределах процесса; для redis — два клиента, указывающих на один сервер.
+// expectCrossInstanceSafe фиксирует, каким должен быть результат для этого
+// backend'а, а не предполагает, что все backend'ы одинаково защищены.
+type nonceTrackerBackend struct {
+	name                    string
+	expectCrossInstanceSafe bool
+	setup                   func() (a, b crypto.NonceTracker, cleanup func(), skip bool)
+}
+
+func nonceTrackerBackends() []nonceTrackerBackend {
+	return []nonceTrackerBackend{
+		{
+			name:                    "memory",
+			expectCrossInstanceSafe: false,
+			setup: func() (crypto.NonceTracker, crypto.NonceTracker, func(), bool) {
+				a := crypto.NewMemoryNonceTracker(1000, time.Minute)
+				b := crypto.NewMemoryNonceTracker(1000, time.Minute)
+				return a, b, func() { a.Stop(); b.Stop() }, false
+			},
+		},
+		{
+			name:                    "bbolt",
+			expectCrossInstanceSafe: true,
+			setup: func() (crypto.NonceTracker, crypto.NonceTracker, func(), bool) {
+				f, err := os.CreateTemp("", "nonce-tracker-*.bolt")
+				if err != nil {
+					return nil, nil, func() {}, true
+				}
+				path := f.Name()
+				f.Close()
+				os.Remove(path)
+
+				tracker, err := crypto.NewBoltNonceTracker(path)
+				if err != nil {
+					return nil, nil, func() {}, true
+				}
+				cleanup := func() {
+					tracker.Stop()
+					os.Remove(path)
+				}
+				// bbolt — хранилище одного узла: "инстанс A" и "инстанс B" здесь —
+				// один и тот же трекер над общим файлом, а не два процесса.
+				return tracker, tracker, cleanup, false
+			},
+		},
+		{
+			name:                    "redis",
+			expectCrossInstanceSafe: true,
+			setup: func() (crypto.NonceTracker, crypto.NonceTracker, func(), bool) {
+				clientA := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				if err := clientA.Ping(ctx).Err(); err != nil {
+					clientA.Close()
+					return nil, nil, func() {}, true
+				}
+				clientB := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+				prefix := fmt.Sprintf("nonce-test-%d:", time.Now().UnixNano())
+				trackerA := crypto.NewRedisNonceTracker(clientA, prefix)
+				trackerB := crypto.NewRedisNonceTracker(clientB, prefix)
+				cleanup := func() {
+					trackerA.Reset()
+					trackerA.Stop()
+					trackerB.Stop()
+				}
+				return trackerA, trackerB, cleanup, false
+			},
+		},
+	}
+}
+
 type SecurityTestResult struct {
 	TestName          string                 `json:"testName"`
 	Success           bool                   `json:"success"`
@@ -37,6 +119,7 @@ func RunSecurityTests() []SecurityTestResult {
 	results = append(results, testConcurrentAccess())
 	results = append(results, testMessageIntegrity())
 	results = append(results, testDifferentKeySizes())
+	results = append(results, testAEADTamperResistance())
 
 	return results
 }
@@ -145,14 +228,43 @@ func testTimingAttackResistance() SecurityTestResult {
 
 	timeDifference := absDuration(avgCorrect - avgWrong)
 
+	// Тот же анализ для crypto.BLAKE2bMAC (см. mac.go) — обе реализации MAC
+	// должны одинаково не зависеть от правильности тега по времени проверки.
+	blake2b := crypto.BLAKE2bMAC{}
+	correctTag := blake2b.Sum(key, []byte("test data"))
+
+	var blake2bCorrectTimes []time.Duration
+	for i := 0; i < 1000; i++ {
+		start := time.Now()
+		blake2b.Verify(key, []byte("test data"), correctTag)
+		blake2bCorrectTimes = append(blake2bCorrectTimes, time.Since(start))
+	}
+
+	wrongTag := make([]byte, len(correctTag))
+	rand.Read(wrongTag)
+
+	var blake2bWrongTimes []time.Duration
+	for i := 0; i < 1000; i++ {
+		start := time.Now()
+		blake2b.Verify(key, []byte("test data"), wrongTag)
+		blake2bWrongTimes = append(blake2bWrongTimes, time.Since(start))
+	}
+
+	avgBlake2bCorrect := averageDuration(blake2bCorrectTimes)
+	avgBlake2bWrong := averageDuration(blake2bWrongTimes)
+	blake2bTimeDifference := absDuration(avgBlake2bCorrect - avgBlake2bWrong)
+
 	return SecurityTestResult{
 		TestName:    "Timing Attack Resistance",
-		Success:     timeDifference < time.Microsecond*100, // Разница менее 100 микросекунд
-		Description: "Tests if HMAC verification is resistant to timing attacks",
+		Success:     timeDifference < time.Microsecond*100 && blake2bTimeDifference < time.Microsecond*100,
+		Description: "Tests if MAC verification (HMAC-SHA256, BLAKE2b) is resistant to timing attacks",
 		AdditionalMetrics: map[string]interface{}{
-			"avgCorrectTimeNs": avgCorrect.Nanoseconds(),
-			"avgWrongTimeNs":   avgWrong.Nanoseconds(),
-			"timeDifferenceNs": timeDifference.Nanoseconds(),
+			"avgCorrectTimeNs":        avgCorrect.Nanoseconds(),
+			"avgWrongTimeNs":          avgWrong.Nanoseconds(),
+			"timeDifferenceNs":        timeDifference.Nanoseconds(),
+			"blake2bAvgCorrectTimeNs": avgBlake2bCorrect.Nanoseconds(),
+			"blake2bAvgWrongTimeNs":   avgBlake2bWrong.Nanoseconds(),
+			"blake2bTimeDifferenceNs": blake2bTimeDifference.Nanoseconds(),
 		},
 	}
 }
@@ -183,13 +295,47 @@ func testReplayAttackPrevention() SecurityTestResult {
 
 	_, err2 := crypto.VerifyAndDecryptMessage(oldMsg, sharedSecret, rsaPub, stats)
 
+	// Табличная проверка по backend'ам NonceTracker (см. WithNonceTracker,
+	// securemessage.go/noncetracker.go): сообщение "принимается инстансом A" через
+	// трекер a, затем то же сообщение (replay) подается "инстансу B" через
+	// трекер b. Ожидаемый результат зависит от backend'а — для memory он
+	// намеренно "небезопасен" (демонстрирует исходную проблему из
+	// chunk3-5), для bbolt/redis ожидается согласованное отклонение.
+	backendResults := make(map[string]bool)
+	backendSkipped := make(map[string]bool)
+	for _, backend := range nonceTrackerBackends() {
+		trackerA, trackerB, cleanup, skip := backend.setup()
+		if skip {
+			backendSkipped[backend.name] = true
+			continue
+		}
+
+		msg := crypto.CreateSecureMessage([]byte("cross-instance replay test"), sharedSecret, ecdsaPriv, ecdsaPub, rsaPriv, stats)
+
+		_, errA := crypto.VerifyAndDecryptMessageWithConfig(msg, sharedSecret, rsaPub, stats, crypto.WithNonceTracker(trackerA))
+		_, errB := crypto.VerifyAndDecryptMessageWithConfig(msg, sharedSecret, rsaPub, stats, crypto.WithNonceTracker(trackerB))
+		crossInstanceSafe := errA == nil && errB != nil
+
+		backendResults[backend.name] = crossInstanceSafe == backend.expectCrossInstanceSafe
+		cleanup()
+	}
+	allBackendsAsExpected := true
+	for _, ok := range backendResults {
+		if !ok {
+			allBackendsAsExpected = false
+			break
+		}
+	}
+
 	return SecurityTestResult{
 		TestName:    "Replay Attack Prevention",
-		Success:     err1 == nil && err2 != nil,
-		Description: "Tests prevention of replay attacks using timestamps",
+		Success:     err1 == nil && err2 != nil && allBackendsAsExpected,
+		Description: "Tests prevention of replay attacks using timestamps, and cross-instance behavior of each NonceTracker backend (memory, bbolt, redis)",
 		AdditionalMetrics: map[string]interface{}{
 			"firstAttemptSuccess":  err1 == nil,
 			"secondAttemptSuccess": err2 == nil,
+			"backendAsExpected":    backendResults,
+			"backendSkipped":       backendSkipped,
 			"errorMessage": func() string {
 				if err2 != nil {
 					return err2.Error()
@@ -315,16 +461,64 @@ func testConcurrentAccess() SecurityTestResult {
 
 	successRate := float64(successCount) / float64(totalMessages)
 
+	// Табличная проверка атомарности AddNonce каждого backend'а NonceTracker
+	// под конкуренцией: один и тот же nonce подается из множества горутин
+	// одному общему трекеру — проверка-и-вставка должна быть атомарной, так
+	// что ровно одна горутина получает nil, а остальные — ошибку replay.
+	const racers = 50
+	backendAtomic := make(map[string]bool)
+	backendSkipped := make(map[string]bool)
+	for _, backend := range nonceTrackerBackends() {
+		tracker, _, cleanup, skip := backend.setup()
+		if skip {
+			backendSkipped[backend.name] = true
+			continue
+		}
+
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+
+		var raceWg sync.WaitGroup
+		successes := make(chan bool, racers)
+		for i := 0; i < racers; i++ {
+			raceWg.Add(1)
+			go func() {
+				defer raceWg.Done()
+				successes <- tracker.AddNonce(nonce, time.Minute) == nil
+			}()
+		}
+		raceWg.Wait()
+		close(successes)
+
+		acceptedCount := 0
+		for ok := range successes {
+			if ok {
+				acceptedCount++
+			}
+		}
+		backendAtomic[backend.name] = acceptedCount == 1
+		cleanup()
+	}
+	allBackendsAtomic := true
+	for _, ok := range backendAtomic {
+		if !ok {
+			allBackendsAtomic = false
+			break
+		}
+	}
+
 	return SecurityTestResult{
 		TestName:    "Concurrent Access",
-		Success:     successRate > 0.99, // 99% успешных операций
-		Description: "Tests concurrent encryption/decryption operations",
+		Success:     successRate > 0.99 && allBackendsAtomic, // 99% успешных операций
+		Description: "Tests concurrent encryption/decryption operations and atomic check-and-insert of each NonceTracker backend (memory, bbolt, redis)",
 		AdditionalMetrics: map[string]interface{}{
 			"totalMessages":      totalMessages,
 			"successfulMessages": successCount,
 			"successRate":        successRate,
 			"totalDurationMs":    totalDuration.Milliseconds(),
 			"messagesPerSecond":  float64(totalMessages) / totalDuration.Seconds(),
+			"backendAtomic":      backendAtomic,
+			"backendSkipped":     backendSkipped,
 		},
 	}
 }
@@ -352,12 +546,46 @@ func testMessageIntegrity() SecurityTestResult {
 	// Тест должен обнаружить изменение и вернуть ошибку
 	integrityProtected := err != nil
 
+	// Та же проверка для каждой реализации MAC (см. CreateSecureMessageWithMAC,
+	// mac.go) — подмена шифротекста должна быть обнаружена независимо от
+	// того, каким алгоритмом аутентифицирован Cipher.
+	macAlgoResults := make(map[int]bool)
+	for _, macAlgo := range []byte{crypto.MACAlgoHMACSHA256, crypto.MACAlgoBLAKE2b} {
+		crypto.ResetNonceStorage()
+		msg, err := crypto.CreateSecureMessageWithMAC(testMessage, sharedSecret, ecdsaPriv, ecdsaPub, rsaPriv, crypto.SigAlgPKCS1v15, crypto.SuiteAESCBCHMAC, macAlgo, crypto.DefaultRand, stats)
+		if err != nil {
+			macAlgoResults[int(macAlgo)] = false
+			continue
+		}
+
+		decrypted, err := crypto.VerifyAndDecryptMessage(msg, sharedSecret, rsaPub, stats)
+		honestOK := err == nil && bytes.Equal(decrypted, testMessage)
+
+		tampered := msg
+		tampered.Cipher = append([]byte{}, msg.Cipher...)
+		if len(tampered.Cipher) > 0 {
+			tampered.Cipher[0] ^= 1
+		}
+		crypto.ResetNonceStorage()
+		_, tamperErr := crypto.VerifyAndDecryptMessage(tampered, sharedSecret, rsaPub, stats)
+
+		macAlgoResults[int(macAlgo)] = honestOK && tamperErr != nil
+	}
+	allMACAlgosProtected := true
+	for _, ok := range macAlgoResults {
+		if !ok {
+			allMACAlgosProtected = false
+			break
+		}
+	}
+
 	return SecurityTestResult{
 		TestName:    "Message Integrity",
-		Success:     integrityProtected,
-		Description: "Tests detection of message tampering",
+		Success:     integrityProtected && allMACAlgosProtected,
+		Description: "Tests detection of message tampering across MAC implementations (HMAC-SHA256, BLAKE2b)",
 		AdditionalMetrics: map[string]interface{}{
 			"tamperingDetected": integrityProtected,
+			"macAlgoResults":    macAlgoResults,
 			"errorMessage": func() string {
 				if err != nil {
 					return err.Error()
@@ -369,28 +597,35 @@ func testMessageIntegrity() SecurityTestResult {
 }
 
 func testDifferentKeySizes() SecurityTestResult {
-	// Тест с разными размерами ключей (в данном случае RSA)
+	// Тест с разными размерами ключей RSA (2048/3072/4096 бит) и обеими
+	// схемами набивки (PKCS1v15, PSS) — см. GenerateRSAKeysWithSize,
+	// SignRSA/SignRSAPSS.
 	stats := metrics.NewSecurityStats()
 
-	keySizes := []int{2048} // В текущей реализации только 2048
+	keySizes := []int{2048, 3072, 4096}
 	results := make(map[int]bool)
-	timings := make(map[int]time.Duration)
+	timingsMs := make(map[string]int64)
 
 	testData := []byte("Key size test data")
 
 	for _, keySize := range keySizes {
 		stats.SetKeyLength(keySize)
-
-		startTime := time.Now()
-		rsaPriv, rsaPub := crypto.GenerateRSAKeys()
-
-		signature := crypto.SignRSA(rsaPriv, testData, stats)
-		verified := crypto.VerifyRSA(rsaPub, testData, signature, stats)
-
-		duration := time.Since(startTime)
-
-		results[keySize] = verified
-		timings[keySize] = duration
+		rsaPriv, rsaPub := crypto.GenerateRSAKeysWithSize(keySize)
+
+		pkcs1v15Start := time.Now()
+		pkcs1v15Sig := crypto.SignRSA(rsaPriv, testData, stats)
+		pkcs1v15Verified := crypto.VerifyRSA(rsaPub, testData, pkcs1v15Sig, stats)
+		pkcs1v15Duration := time.Since(pkcs1v15Start)
+
+		pssStart := time.Now()
+		pssSig, err := crypto.SignRSAPSS(rsaPriv, testData, stats)
+		pssVerified := err == nil && crypto.VerifyRSAPSS(rsaPub, testData, pssSig, stats)
+		pssDuration := time.Since(pssStart)
+
+		results[keySize] = pkcs1v15Verified && pssVerified
+		keyLabel := fmt.Sprintf("%d", keySize)
+		timingsMs[keyLabel+"-PKCS1v15"] = pkcs1v15Duration.Milliseconds()
+		timingsMs[keyLabel+"-PSS"] = pssDuration.Milliseconds()
 	}
 
 	allSuccessful := true
@@ -401,19 +636,101 @@ func testDifferentKeySizes() SecurityTestResult {
 		}
 	}
 
+	keySizeResults := make(map[string]bool)
+	for size, success := range results {
+		keySizeResults[fmt.Sprintf("%d", size)] = success
+	}
+
 	return SecurityTestResult{
 		TestName:    "Different Key Sizes",
 		Success:     allSuccessful,
-		Description: "Tests RSA with different key sizes",
+		Description: "Tests RSA with key sizes 2048/3072/4096, comparing PKCS1v15 and PSS padding",
 		AdditionalMetrics: map[string]interface{}{
-			"keySizeResults": results,
-			"keySizeTimings": func() map[string]int64 {
-				timingResults := make(map[string]int64)
-				for size, timing := range timings {
-					timingResults[string(rune(size))] = timing.Milliseconds()
-				}
-				return timingResults
-			}(),
+			"keySizeResults":   keySizeResults,
+			"keySizeTimingsMs": timingsMs,
+		},
+	}
+}
+
+// testAEADTamperResistance проверяет CreateSecureMessageAEAD/
+// VerifyAndDecryptMessageAEAD обоими поддерживаемыми наборами шифров
+// (SuiteAES256GCM, SuiteChaCha20Poly1305): честная расшифровка должна
+// проходить, а затем фаззинг — переворачивание отдельных бит в полях,
+// которые входят в AAD (Timestamp и PubKey), но не в сам шифротекст —
+// должен каждый раз приводить к отказу. Отказом считается любая ошибка
+// VerifyAndDecryptMessageAEAD: до аутентификации AEAD доходят только
+// перевороты бит 0-4 Timestamp (сдвиг не больше 16 с, внутри окна
+// MaxTimeDifference) и подмена PubKey; перевороты бит 5-31 выводят
+// Timestamp за окно и отвергаются проверкой временной метки до AEAD.Open.
+func testAEADTamperResistance() SecurityTestResult {
+	stats := metrics.NewSecurityStats()
+	stats.SetKeyLength(256)
+
+	ecdsaPriv, ecdsaPub := crypto.GenerateECDHKeys()
+	rsaPriv, rsaPub := crypto.GenerateRSAKeys()
+	sharedSecret := make([]byte, 64)
+	rand.Read(sharedSecret)
+
+	sessionAAD := []byte("session-42")
+	testMessage := []byte("AEAD tamper resistance test message")
+
+	suites := []crypto.CipherSuite{crypto.SuiteAES256GCM, crypto.SuiteChaCha20Poly1305}
+	honestRoundTripOK := true
+	tamperDetections := 0
+	tamperAttempts := 0
+
+	for _, suite := range suites {
+		crypto.ResetNonceStorage()
+		msg, err := crypto.CreateSecureMessageAEAD(testMessage, sharedSecret, suite, ecdsaPriv, ecdsaPub, rsaPriv, crypto.SigAlgPKCS1v15, sessionAAD, crypto.DefaultRand, stats)
+		if err != nil {
+			honestRoundTripOK = false
+			continue
+		}
+		decrypted, err := crypto.VerifyAndDecryptMessageAEAD(msg, sharedSecret, rsaPub, sessionAAD, stats)
+		if err != nil || !bytes.Equal(decrypted, testMessage) {
+			honestRoundTripOK = false
+		}
+
+		// Переворачиваем по одному биту в каждом из 32 младших бит Timestamp и
+		// в первом байте PubKey, каждый раз проверяя, что AEAD отвергает
+		// сообщение. Nonce сбрасывается перед каждой попыткой отдельно от
+		// честной расшифровки выше, чтобы отказ не мог быть вызван повторным
+		// использованием nonce; для бит 5-31 отказ дает проверка окна
+		// Timestamp (MaxTimeDifference), а не несовпадение AAD.
+		for i := 0; i < 32; i++ {
+			tampered := msg
+			tampered.Timestamp = msg.Timestamp ^ int64(1<<uint(i))
+			tamperAttempts++
+			crypto.ResetNonceStorage()
+			if _, err := crypto.VerifyAndDecryptMessageAEAD(tampered, sharedSecret, rsaPub, sessionAAD, stats); err != nil {
+				tamperDetections++
+			}
+		}
+
+		if len(msg.PubKey) > 0 {
+			tamperedPubKey := make([]byte, len(msg.PubKey))
+			copy(tamperedPubKey, msg.PubKey)
+			tamperedPubKey[0] ^= 0xFF
+			tampered := msg
+			tampered.PubKey = tamperedPubKey
+			tamperAttempts++
+			crypto.ResetNonceStorage()
+			if _, err := crypto.VerifyAndDecryptMessageAEAD(tampered, sharedSecret, rsaPub, sessionAAD, stats); err != nil {
+				tamperDetections++
+			}
+		}
+	}
+
+	allTampersDetected := tamperAttempts > 0 && tamperDetections == tamperAttempts
+
+	return SecurityTestResult{
+		TestName:    "AEAD AAD Tamper Resistance",
+		Success:     honestRoundTripOK && allTampersDetected,
+		Description: "Fuzzes Timestamp/PubKey bits bound as AEAD additional data and asserts authentication failure",
+		AdditionalMetrics: map[string]interface{}{
+			"honestRoundTripOK": honestRoundTripOK,
+			"tamperAttempts":    tamperAttempts,
+			"tamperDetections":  tamperDetections,
 		},
 	}
 }